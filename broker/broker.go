@@ -1,37 +1,92 @@
 package broker
 
 import (
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/k8spacket/k8spacket/broker/prometheus"
 	"github.com/k8spacket/k8spacket/modules"
 )
 
 type Broker struct {
 	IBroker
-	NodegraphListener modules.IListener[modules.TCPEvent]
-	TlsParserListener modules.IListener[modules.TLSEvent]
-	tcpEventChannel   chan modules.TCPEvent
-	tlsEventChannel   chan modules.TLSEvent
+	NodegraphListener    modules.IListener[modules.TCPEvent]
+	TlsParserListener    modules.IListener[modules.TLSEvent]
+	LoopbackListener     modules.IListener[modules.LoopbackEvent]
+	tcpEventChannel      chan modules.TCPEvent
+	tlsEventChannel      chan modules.TLSEvent
+	loopbackEventChannel chan modules.LoopbackEvent
+	throttled            atomic.Bool
+	samplingRate         uint64
+	tcpEventCounter      atomic.Uint64
 }
 
-func Init(nodegraphListener modules.IListener[modules.TCPEvent], tlsParserListener modules.IListener[modules.TLSEvent]) *Broker {
-	broker := Broker{NodegraphListener: nodegraphListener, TlsParserListener: tlsParserListener}
-	broker.tcpEventChannel = make(chan modules.TCPEvent)
-	broker.tlsEventChannel = make(chan modules.TLSEvent)
+func Init(nodegraphListener modules.IListener[modules.TCPEvent], tlsParserListener modules.IListener[modules.TLSEvent], loopbackListener modules.IListener[modules.LoopbackEvent]) *Broker {
+	broker := Broker{NodegraphListener: nodegraphListener, TlsParserListener: tlsParserListener, LoopbackListener: loopbackListener}
+	bufferSize, err := strconv.Atoi(os.Getenv("K8S_PACKET_BROKER_BUFFER_SIZE"))
+	if err != nil || bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	broker.tcpEventChannel = make(chan modules.TCPEvent, bufferSize)
+	broker.tlsEventChannel = make(chan modules.TLSEvent, bufferSize)
+	broker.loopbackEventChannel = make(chan modules.LoopbackEvent, bufferSize)
+	broker.samplingRate, _ = strconv.ParseUint(os.Getenv("K8S_PACKET_PRESSURE_TCP_SAMPLING_RATE"), 10, 64)
+	if broker.samplingRate == 0 {
+		broker.samplingRate = 10
+	}
 	return &broker
 }
 
+// when throttled, only every n-th TCP event is passed (K8S_PACKET_PRESSURE_TCP_SAMPLING_RATE),
+// TLS events are kept, but the tc programs don't parse new handshakes meanwhile
+func (broker *Broker) Throttle(throttled bool) {
+	broker.throttled.Store(throttled)
+}
+
+// TCP events are high-volume connection counters with low priority, they are shed when the buffer is full
 func (broker *Broker) TCPEvent(event modules.TCPEvent) {
-	broker.tcpEventChannel <- event
+	if broker.throttled.Load() && broker.tcpEventCounter.Add(1)%broker.samplingRate != 0 {
+		prometheus.K8sPacketShedEventsMetric.WithLabelValues("tcp", "throttled").Inc()
+		return
+	}
+	select {
+	case broker.tcpEventChannel <- event:
+	default:
+		prometheus.K8sPacketShedEventsMetric.WithLabelValues("tcp", "buffer_full").Inc()
+	}
 }
 
+// loopback events are opt-in debugging data, they are shed when the buffer is full like TCP events, but not throttled,
+// as they are captured only if K8S_PACKET_LOOPBACK_ENABLED is set
+func (broker *Broker) LoopbackEvent(event modules.LoopbackEvent) {
+	select {
+	case broker.loopbackEventChannel <- event:
+	default:
+		prometheus.K8sPacketShedEventsMetric.WithLabelValues("loopback", "buffer_full").Inc()
+	}
+}
+
+// TLS events have high priority, they wait for free space in the buffer instead of being shed
 func (broker *Broker) TLSEvent(event modules.TLSEvent) {
 	broker.tlsEventChannel <- event
 }
 
 func (broker *Broker) DistributeEvents() {
 	for {
+		// drain pending TLS events before TCP events
+		select {
+		case event := <-broker.tlsEventChannel:
+			broker.TlsParserListener.Listen(event)
+			continue
+		default:
+		}
+
 		select {
 		case event := <-broker.tcpEventChannel:
 			broker.NodegraphListener.Listen(event)
+		case event := <-broker.loopbackEventChannel:
+			broker.LoopbackListener.Listen(event)
 		case event := <-broker.tlsEventChannel:
 			broker.TlsParserListener.Listen(event)
 		}
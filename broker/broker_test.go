@@ -1,10 +1,14 @@
 package broker
 
 import (
+	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/k8spacket/k8spacket/broker/prometheus"
 	"github.com/k8spacket/k8spacket/modules"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,12 +30,22 @@ func (mockTlsParserListener *mockTlsParserListener) Listen(event modules.TLSEven
 	mockTlsParserListener.listenerCalled = true
 }
 
+type mockLoopbackListener struct {
+	modules.IListener[modules.LoopbackEvent]
+	listenerCalled atomic.Bool
+}
+
+func (mockLoopbackListener *mockLoopbackListener) Listen(event modules.LoopbackEvent) {
+	mockLoopbackListener.listenerCalled.Store(true)
+}
+
 func TestDistributeEvents(t *testing.T) {
 
 	mockNodegraphListener := &mockNodegraphListener{}
 	mockTlsParserListener := &mockTlsParserListener{}
+	mockLoopbackListener := &mockLoopbackListener{}
 
-	broker := Init(mockNodegraphListener, mockTlsParserListener)
+	broker := Init(mockNodegraphListener, mockTlsParserListener, mockLoopbackListener)
 
 	go broker.DistributeEvents()
 
@@ -43,8 +57,104 @@ func TestDistributeEvents(t *testing.T) {
 
 	broker.TLSEvent(modules.TLSEvent{Client: modules.Address{Addr: "addr1"}, ServerName: "k8spacket.io"})
 
+	broker.LoopbackEvent(modules.LoopbackEvent{Client: modules.Address{Addr: "127.0.0.1"}, Process: modules.Process{Comm: "envoy"}})
+
 	assert.Eventually(t, func() bool {
-		return mockNodegraphListener.listenerCalled && mockTlsParserListener.listenerCalled
+		return mockNodegraphListener.listenerCalled && mockTlsParserListener.listenerCalled && mockLoopbackListener.listenerCalled.Load()
 	}, time.Second*1, time.Millisecond*100)
 
 }
+
+type countingNodegraphListener struct {
+	modules.IListener[modules.TCPEvent]
+	count atomic.Int32
+}
+
+func (countingNodegraphListener *countingNodegraphListener) Listen(event modules.TCPEvent) {
+	countingNodegraphListener.count.Add(1)
+}
+
+func TestThrottle(t *testing.T) {
+
+	os.Setenv("K8S_PACKET_PRESSURE_TCP_SAMPLING_RATE", "5")
+
+	nodegraphListener := &countingNodegraphListener{}
+	tlsParserListener := &mockTlsParserListener{}
+
+	broker := Init(nodegraphListener, tlsParserListener, &mockLoopbackListener{})
+
+	go broker.DistributeEvents()
+
+	broker.Throttle(true)
+
+	for i := 0; i < 10; i++ {
+		broker.TCPEvent(modules.TCPEvent{Client: modules.Address{Addr: "addr1"}})
+	}
+	broker.TLSEvent(modules.TLSEvent{Client: modules.Address{Addr: "addr1"}})
+
+	assert.Eventually(t, func() bool {
+		return nodegraphListener.count.Load() == 2 && tlsParserListener.listenerCalled
+	}, time.Second*1, time.Millisecond*100)
+
+	broker.Throttle(false)
+
+	broker.TCPEvent(modules.TCPEvent{Client: modules.Address{Addr: "addr1"}})
+
+	assert.Eventually(t, func() bool {
+		return nodegraphListener.count.Load() == 3
+	}, time.Second*1, time.Millisecond*100)
+}
+
+func TestShedLowPriorityEvents(t *testing.T) {
+
+	os.Setenv("K8S_PACKET_BROKER_BUFFER_SIZE", "2")
+
+	broker := Init(&mockNodegraphListener{}, &mockTlsParserListener{}, &mockLoopbackListener{})
+
+	shed := testutil.ToFloat64(prometheus.K8sPacketShedEventsMetric.WithLabelValues("tcp", "buffer_full"))
+
+	for i := 0; i < 5; i++ {
+		broker.TCPEvent(modules.TCPEvent{Client: modules.Address{Addr: "addr1"}})
+	}
+	broker.TLSEvent(modules.TLSEvent{Client: modules.Address{Addr: "addr1"}})
+	broker.TLSEvent(modules.TLSEvent{Client: modules.Address{Addr: "addr1"}})
+
+	assert.EqualValues(t, 2, len(broker.tcpEventChannel))
+	assert.EqualValues(t, 2, len(broker.tlsEventChannel))
+	assert.EqualValues(t, shed+3, testutil.ToFloat64(prometheus.K8sPacketShedEventsMetric.WithLabelValues("tcp", "buffer_full")))
+
+	shedLoopback := testutil.ToFloat64(prometheus.K8sPacketShedEventsMetric.WithLabelValues("loopback", "buffer_full"))
+
+	for i := 0; i < 3; i++ {
+		broker.LoopbackEvent(modules.LoopbackEvent{Client: modules.Address{Addr: "127.0.0.1"}})
+	}
+
+	assert.EqualValues(t, 2, len(broker.loopbackEventChannel))
+	assert.EqualValues(t, shedLoopback+1, testutil.ToFloat64(prometheus.K8sPacketShedEventsMetric.WithLabelValues("loopback", "buffer_full")))
+}
+
+func TestBufferSize(t *testing.T) {
+
+	var tests = []struct {
+		value string
+		want  int
+	}{
+		{"", 1000},
+		{"abc", 1000},
+		{"-1", 1000},
+		{"0", 1000},
+		{"10", 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv("K8S_PACKET_BROKER_BUFFER_SIZE", test.value)
+
+			broker := Init(&mockNodegraphListener{}, &mockTlsParserListener{}, &mockLoopbackListener{})
+
+			assert.EqualValues(t, test.want, cap(broker.tcpEventChannel))
+			assert.EqualValues(t, test.want, cap(broker.tlsEventChannel))
+			assert.EqualValues(t, test.want, cap(broker.loopbackEventChannel))
+		})
+	}
+}
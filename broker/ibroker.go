@@ -8,4 +8,6 @@ type IBroker interface {
 	DistributeEvents()
 	TCPEvent(event modules.TCPEvent)
 	TLSEvent(event modules.TLSEvent)
+	LoopbackEvent(event modules.LoopbackEvent)
+	Throttle(throttled bool)
 }
@@ -0,0 +1,19 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	K8sPacketShedEventsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8s_packet_shed_events",
+			Help: "Kubernetes packet events shed before processing",
+		},
+		[]string{"type", "reason"},
+	)
+)
+
+func Init() {
+	prometheus.MustRegister(K8sPacketShedEventsMetric)
+}
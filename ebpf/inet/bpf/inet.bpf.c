@@ -1,8 +1,14 @@
 #include "vmlinux.h"
 #include "bpf_core_read.h"
+#include "bpf_endian.h"
 #include "bpf_tracing.h"
 
 #define MAX_ENTRIES	100
+#define TASK_COMM_LEN	16
+#define CGROUP_NAME_LEN	128
+#define MAX_FLOWS	10240
+#define MAX_LOOPBACK_OWNERS	4096
+#define MAX_TRUSTED_PROXIES	256
 //#define AF_INET		2
 
 struct event {
@@ -10,6 +16,7 @@ struct event {
 	__be32 daddr; 	// destination IP
     __be16 sport; 	// source port
 	__be16 dport; 	// destination port
+	__u8 initiator;	// observed on the client side of the connection, otherwise on the server side
 	__u64 delta_us;	// duration in microseconds 
 	__u64 rx_b;		// received bytes
 	__u64 tx_b;		// transmited bytes
@@ -20,8 +27,53 @@ struct birth {
     bool initiator;	// am i the initiator?
 };
 
+struct loopback_event {
+	__be32 saddr;						// source IP
+	__be32 daddr;						// destination IP
+	__be16 sport;						// source port
+	__be16 dport;						// destination port
+	__u32 pid;							// process id (tgid) of the connecting process
+	__u64 delta_us;						// duration in microseconds
+	__u64 rx_b;							// received bytes
+	__u64 tx_b;							// transmited bytes
+	char comm[TASK_COMM_LEN];			// command name of the connecting process
+	char cgroup[CGROUP_NAME_LEN];		// cgroup v2 name of the connecting process, e.g. container scope
+	char parent_cgroup[CGROUP_NAME_LEN];	// name of the parent cgroup, e.g. pod slice
+};
+
+//connections from a client to a server port, keyed by persistence to keep the average duration on one side of the threshold
+struct flow_key {
+	__be32 saddr;		// source IP
+	__be32 daddr;		// destination IP
+	__be16 dport;		// destination port
+	__u8 persistent;	// longer than persistent_duration_us
+	__u8 slot;			// slot written by the program when the connection was closed
+	__u8 initiator;		// observed on the client side of the connection, otherwise on the server side
+};
+
+struct trusted_proxy_key {
+	__u32 prefixlen;	// length of the CIDR prefix, 32 for lookups of a single IP
+	__be32 addr;		// IP of the CIDR
+};
+
+struct flow {
+	__u64 count;		// closed connections
+	__u64 delta_us;		// sum of durations in microseconds
+	__u64 max_delta_us;	// longest duration in microseconds
+	__u64 rx_b;			// sum of received bytes
+	__u64 tx_b;			// sum of transmited bytes
+};
+
 //dummy unused instance declaration of type to not be optimized, lack causes: "Error: collect C types: type name event: not found"
 struct event *unused __attribute__((unused));
+struct loopback_event *unused_loopback_event __attribute__((unused));
+
+//set by the loader, loopback connections are captured separately from network connections (K8S_PACKET_LOOPBACK_ENABLED)
+const volatile bool capture_loopback = false;
+
+//set by the loader, connections are summed per flow and read periodically from userspace (K8S_PACKET_FLOW_AGGREGATION_INTERVAL)
+const volatile bool aggregate_flows = false;
+const volatile __u64 persistent_duration_us = 0;
 
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
@@ -36,6 +88,45 @@ struct {
 	__uint(value_size, sizeof(__u32));
 } events SEC(".maps");
 
+//connecting process of open loopback connections, sk sock struct as a key, least recently used are evicted when full
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_LOOPBACK_OWNERS);
+	__type(key, struct sock *);
+	__type(value, struct loopback_event);
+} loopback_owners SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+	__uint(key_size, sizeof(__u32));
+	__uint(value_size, sizeof(__u32));
+} loopback_events SEC(".maps");
+
+//flows of closed connections, userspace switches the slot and drains flows of the previous one
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_FLOWS);
+	__type(key, struct flow_key);
+	__type(value, struct flow);
+} flows SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, __u32);
+} flows_slot SEC(".maps");
+
+//IPv4 CIDRs of trusted proxies set by userspace (K8S_PACKET_TRUSTED_PROXY_CIDRS), their connections are not aggregated,
+//as original clients conveyed by PROXY protocol are known per connection
+struct {
+	__uint(type, BPF_MAP_TYPE_LPM_TRIE);
+	__uint(max_entries, MAX_TRUSTED_PROXIES);
+	__uint(map_flags, BPF_F_NO_PREALLOC);
+	__type(key, struct trusted_proxy_key);
+	__type(value, __u8);
+} trusted_proxies SEC(".maps");
+
 static void source_and_destination(struct trace_event_raw_inet_sock_set_state *args, __be32 *saddr, __u16 *sport, __be32 *daddr, __u16 *dport) {
     //source and destination IPs
 
@@ -52,6 +143,65 @@ static void source_and_destination(struct trace_event_raw_inet_sock_set_state *a
     *dport = BPF_CORE_READ(args, dport);
 }
 
+//destination in 127.0.0.0/8
+static bool is_loopback(struct trace_event_raw_inet_sock_set_state *args) {
+    __be32 daddr;
+    bpf_probe_read_kernel(&daddr, sizeof(daddr), BPF_CORE_READ(args, daddr));
+    return (bpf_ntohl(daddr) >> 24) == 127;
+}
+
+//SYN_SENT is set in the connect() syscall, so the current task is the connecting process
+static void loopback_owner(struct loopback_event *owner) {
+    struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+    struct cgroup *cgrp = BPF_CORE_READ(task, cgroups, dfl_cgrp);
+
+    owner->pid = bpf_get_current_pid_tgid() >> 32;
+    bpf_get_current_comm(&owner->comm, sizeof(owner->comm));
+    bpf_probe_read_kernel_str(&owner->cgroup, sizeof(owner->cgroup), BPF_CORE_READ(cgrp, kn, name));
+    bpf_probe_read_kernel_str(&owner->parent_cgroup, sizeof(owner->parent_cgroup), BPF_CORE_READ(cgrp, self.parent, cgroup, kn, name));
+}
+
+static bool is_trusted_proxy(__be32 addr) {
+    struct trusted_proxy_key key = {32, addr};
+    return bpf_map_lookup_elem(&trusted_proxies, &key) != NULL;
+}
+
+//add closed connection to its flow, false when the flows map is full and the connection has to be sent as an event
+static bool aggregate_flow(struct event *event) {
+    __u32 zero = 0;
+    __u32 *slot = bpf_map_lookup_elem(&flows_slot, &zero);
+    if (!slot)
+        return false;
+
+    struct flow_key key;
+    //padding is hashed as a part of the key
+    __builtin_memset(&key, 0, sizeof(key));
+    key.saddr = event->saddr;
+    key.daddr = event->daddr;
+    key.dport = event->dport;
+    key.persistent = event->delta_us > persistent_duration_us;
+    key.slot = *slot;
+    key.initiator = event->initiator;
+
+    struct flow *flowp = bpf_map_lookup_elem(&flows, &key);
+    if (!flowp) {
+        struct flow flow = {1, event->delta_us, event->delta_us, event->rx_b, event->tx_b};
+        if (bpf_map_update_elem(&flows, &key, &flow, BPF_NOEXIST) == 0)
+            return true;
+        //created by another CPU meanwhile, or the map is full
+        flowp = bpf_map_lookup_elem(&flows, &key);
+        if (!flowp)
+            return false;
+    }
+    __sync_fetch_and_add(&flowp->count, 1);
+    __sync_fetch_and_add(&flowp->delta_us, event->delta_us);
+    __sync_fetch_and_add(&flowp->rx_b, event->rx_b);
+    __sync_fetch_and_add(&flowp->tx_b, event->tx_b);
+    if (event->delta_us > flowp->max_delta_us)
+        flowp->max_delta_us = event->delta_us;
+    return true;
+}
+
 SEC("tracepoint/sock/inet_sock_set_state")
 int inet_sock_set_state(struct trace_event_raw_inet_sock_set_state *args)
 {
@@ -61,6 +211,7 @@ int inet_sock_set_state(struct trace_event_raw_inet_sock_set_state *args)
 	int new_state;
 	struct event event = {};
 	struct birth start = {}, *startp;
+	struct loopback_event owner = {}, *ownerp;
 	struct tcp_sock *tp;
 	struct sock *sk;
 
@@ -81,6 +232,14 @@ int inet_sock_set_state(struct trace_event_raw_inet_sock_set_state *args)
 
 	if (new_state == TCP_SYN_SENT || new_state == TCP_SYN_RECV) {
 
+		//loopback connections are reported once, by the connecting side
+		if (capture_loopback && is_loopback(args)) {
+			if (new_state == TCP_SYN_RECV)
+				return 0;
+			loopback_owner(&owner);
+			bpf_map_update_elem(&loopback_owners, &sk, &owner, BPF_ANY);
+		}
+
 		//start connection timestamp
 		ts = bpf_ktime_get_ns();
 		start.ts = ts;
@@ -105,6 +264,8 @@ int inet_sock_set_state(struct trace_event_raw_inet_sock_set_state *args)
 		    source_and_destination(args, &event.daddr, &event.dport, &event.saddr, &event.sport);
 
 		//duration in microseconds
+		event.initiator = startp->initiator;
+
 		ts = bpf_ktime_get_ns();
 		event.delta_us = (ts - startp->ts) / 1000;
 
@@ -120,8 +281,25 @@ int inet_sock_set_state(struct trace_event_raw_inet_sock_set_state *args)
             event.tx_b = rx_b;
 		}
 
-        //store event in BPF perf event
-		bpf_perf_event_output(args, &events, BPF_F_CURRENT_CPU, &event, sizeof(event));
+		ownerp = bpf_map_lookup_elem(&loopback_owners, &sk);
+		if (ownerp) {
+			ownerp->saddr = event.saddr;
+			ownerp->daddr = event.daddr;
+			ownerp->sport = event.sport;
+			ownerp->dport = event.dport;
+			ownerp->delta_us = event.delta_us;
+			ownerp->rx_b = event.rx_b;
+			ownerp->tx_b = event.tx_b;
+
+			//store loopback event in BPF perf event, separately from network connections
+			bpf_perf_event_output(args, &loopback_events, BPF_F_CURRENT_CPU, ownerp, sizeof(*ownerp));
+			bpf_map_delete_elem(&loopback_owners, &sk);
+		} else if (capture_loopback && is_loopback(args)) {
+			//owner evicted from the full map, the connection is not a network one either, so it is dropped
+		} else if (!aggregate_flows || is_trusted_proxy(event.saddr) || !aggregate_flow(&event)) {
+			//store event in BPF perf event
+			bpf_perf_event_output(args, &events, BPF_F_CURRENT_CPU, &event, sizeof(event));
+		}
 
 		//remove element from births based on sock struct
 		bpf_map_delete_elem(&births, &sk);
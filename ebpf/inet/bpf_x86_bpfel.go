@@ -19,14 +19,52 @@ type bpfBirth struct {
 }
 
 type bpfEvent struct {
-	Saddr   uint32
-	Daddr   uint32
-	Sport   uint16
-	Dport   uint16
-	_       [4]byte
-	DeltaUs uint64
-	RxB     uint64
-	TxB     uint64
+	Saddr     uint32
+	Daddr     uint32
+	Sport     uint16
+	Dport     uint16
+	Initiator uint8
+	_         [3]byte
+	DeltaUs   uint64
+	RxB       uint64
+	TxB       uint64
+}
+
+type bpfFlow struct {
+	Count      uint64
+	DeltaUs    uint64
+	MaxDeltaUs uint64
+	RxB        uint64
+	TxB        uint64
+}
+
+type bpfFlowKey struct {
+	Saddr      uint32
+	Daddr      uint32
+	Dport      uint16
+	Persistent uint8
+	Slot       uint8
+	Initiator  uint8
+	_          [3]byte
+}
+
+type bpfLoopbackEvent struct {
+	Saddr        uint32
+	Daddr        uint32
+	Sport        uint16
+	Dport        uint16
+	Pid          uint32
+	DeltaUs      uint64
+	RxB          uint64
+	TxB          uint64
+	Comm         [16]int8
+	Cgroup       [128]int8
+	ParentCgroup [128]int8
+}
+
+type bpfTrustedProxyKey struct {
+	Prefixlen uint32
+	Addr      uint32
 }
 
 // loadBpf returns the embedded CollectionSpec for bpf.
@@ -77,8 +115,13 @@ type bpfProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
-	Births *ebpf.MapSpec `ebpf:"births"`
-	Events *ebpf.MapSpec `ebpf:"events"`
+	Births         *ebpf.MapSpec `ebpf:"births"`
+	Events         *ebpf.MapSpec `ebpf:"events"`
+	Flows          *ebpf.MapSpec `ebpf:"flows"`
+	FlowsSlot      *ebpf.MapSpec `ebpf:"flows_slot"`
+	LoopbackEvents *ebpf.MapSpec `ebpf:"loopback_events"`
+	LoopbackOwners *ebpf.MapSpec `ebpf:"loopback_owners"`
+	TrustedProxies *ebpf.MapSpec `ebpf:"trusted_proxies"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -100,14 +143,24 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
-	Births *ebpf.Map `ebpf:"births"`
-	Events *ebpf.Map `ebpf:"events"`
+	Births         *ebpf.Map `ebpf:"births"`
+	Events         *ebpf.Map `ebpf:"events"`
+	Flows          *ebpf.Map `ebpf:"flows"`
+	FlowsSlot      *ebpf.Map `ebpf:"flows_slot"`
+	LoopbackEvents *ebpf.Map `ebpf:"loopback_events"`
+	LoopbackOwners *ebpf.Map `ebpf:"loopback_owners"`
+	TrustedProxies *ebpf.Map `ebpf:"trusted_proxies"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.Births,
 		m.Events,
+		m.Flows,
+		m.FlowsSlot,
+		m.LoopbackEvents,
+		m.LoopbackOwners,
+		m.TrustedProxies,
 	)
 }
 
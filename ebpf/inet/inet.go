@@ -9,14 +9,20 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/perf"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/k8spacket/k8spacket/broker"
 	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
 	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/security"
 )
 
 /*
@@ -24,29 +30,41 @@ bpf2go - eBPF bytecode compiler & go files generator
 args:
 -cc clang - select C compiler
 -target native - means get target platform from golang env GOARCH
--type event - name of type in C ebpf program to generate Go declaration
+-type event -type loopback_event - names of types in C ebpf program to generate Go declarations
 bpf - identity name of generating program
 ./bpf/inet.bpf.c - C language source file
 */
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc clang -target native -type event bpf ./bpf/inet.bpf.c
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc clang -target native -type event -type loopback_event bpf ./bpf/inet.bpf.c
 
 type InetEbpf struct {
 	Broker broker.IBroker
 }
 
+// connections over the loopback interface are captured separately from network connections, see modules/loopback
+var captureLoopback, _ = strconv.ParseBool(os.Getenv("K8S_PACKET_LOOPBACK_ENABLED"))
+
+// connections are summed per flow in the kernel and sent as one event per flow every interval, disabled by default
+var flowAggregationInterval, _ = time.ParseDuration(os.Getenv("K8S_PACKET_FLOW_AGGREGATION_INTERVAL"))
+
+// pod UID in a cgroup name, e.g. kubepods-burstable-pod{{uid}}.slice (systemd driver) or pod{{uid}} (cgroupfs driver)
+var podCgroupRegexp = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
 func (inetEbpf *InetEbpf) Init() {
 
 	slog.Info("INIT inet")
+	security.UseFeature(security.FeatureEbpf)
 	// Allow the current process to lock more memory than the default for eBPF resources. Default value is 64KB
 	// https://prototype-kernel.readthedocs.io/en/latest/bpf/troubleshooting.html#memory-ulimits
-	// requires on kernels < 5.11 to remove memlock (error: failed to set memlock rlimit: operation not permitted)
+	// requires on kernels < 5.11 to remove memlock (error: failed to set memlock rlimit: operation not permitted),
+	// newer kernels account eBPF memory to the cgroup and nothing is changed
 	if err := rlimit.RemoveMemlock(); err != nil {
-		slog.Error("[inet] Remove memlock", "Error", err)
+		slog.Error("[inet] Cannot remove memlock rlimit, kernels older than 5.11 need CAP_SYS_RESOURCE, run the agent as root with SYS_RESOURCE added to the container capabilities", "Error", err)
+		return
 	}
 
 	// Load pre-compiled programs and maps into the kernel.
 	objs := bpfObjects{}
-	if err := loadBpfObjects(&objs, nil); err != nil {
+	if err := loadObjects(&objs); err != nil {
 		slog.Error("[inet] Loading objects", "Error", err)
 	}
 	defer objs.Close()
@@ -89,6 +107,22 @@ func (inetEbpf *InetEbpf) Init() {
 		}
 	}()
 
+	if flowAggregationInterval > 0 {
+		setTrustedProxies(objs.bpfMaps.TrustedProxies, ebpf_tools.TrustedProxyCIDRs())
+		go inetEbpf.aggregateFlows(objs.bpfMaps.Flows, objs.bpfMaps.FlowsSlot)
+	}
+
+	if captureLoopback {
+		// create new reader for loopback perf events
+		lrd, err := perf.NewReader(objs.bpfMaps.LoopbackEvents, os.Getpagesize())
+		if err != nil {
+			slog.Error("[inet] Creating loopback perf event reader", "Error", err)
+		} else {
+			defer lrd.Close()
+			go inetEbpf.readLoopback(lrd)
+		}
+	}
+
 	// graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -98,6 +132,116 @@ func (inetEbpf *InetEbpf) Init() {
 	slog.Info("[inet] Closed gracefully")
 }
 
+// capture_loopback, aggregate_flows and persistent_duration_us are read-only constants of the eBPF program,
+// they are set before loading into the kernel
+func loadObjects(objs *bpfObjects) error {
+	spec, err := loadBpf()
+	if err != nil {
+		return err
+	}
+	if err := spec.RewriteConstants(map[string]interface{}{
+		"capture_loopback":       captureLoopback,
+		"aggregate_flows":        flowAggregationInterval > 0,
+		"persistent_duration_us": persistentDurationUs(os.Getenv("K8S_PACKET_TCP_PERSISTENT_DURATION"))}); err != nil {
+		return err
+	}
+	return spec.LoadAndAssign(objs, nil)
+}
+
+// node graph takes connections longer than K8S_PACKET_TCP_PERSISTENT_DURATION in whole milliseconds as persistent
+func persistentDurationUs(value string) uint64 {
+	persistentDuration, _ := time.ParseDuration(value)
+	return uint64(max(persistentDuration.Milliseconds(), 0)+1)*1000 - 1
+}
+
+// connections of trusted proxies are not aggregated, so their original clients are found and forgotten per connection
+func setTrustedProxies(trustedProxies *ebpf.Map, cidrs []*net.IPNet) {
+	for _, key := range trustedProxyKeys(cidrs) {
+		if err := trustedProxies.Put(key, uint8(1)); err != nil {
+			slog.Error("[inet] Setting trusted proxy", "Error", err)
+		}
+	}
+}
+
+// the eBPF program handles IPv4 only, the address is kept in network byte order as in events
+func trustedProxyKeys(cidrs []*net.IPNet) []bpfTrustedProxyKey {
+	var keys []bpfTrustedProxyKey
+	for _, cidr := range cidrs {
+		ones, bits := cidr.Mask.Size()
+		if ip := cidr.IP.To4(); ip != nil && bits == 32 {
+			keys = append(keys, bpfTrustedProxyKey{Prefixlen: uint32(ones), Addr: binary.LittleEndian.Uint32(ip)})
+		}
+	}
+	return keys
+}
+
+func (inetEbpf *InetEbpf) aggregateFlows(flows *ebpf.Map, flowsSlot *ebpf.Map) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var slot uint8
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(flowAggregationInterval):
+			slot = inetEbpf.drainFlows(flows, flowsSlot, slot)
+		}
+	}
+}
+
+// the program is switched to the other slot first, so flows of the previous slot are not updated while they are drained
+func (inetEbpf *InetEbpf) drainFlows(flows *ebpf.Map, flowsSlot *ebpf.Map, slot uint8) uint8 {
+	if err := flowsSlot.Put(uint32(0), uint32(slot^1)); err != nil {
+		slog.Error("[inet] Switching flows slot", "Error", err)
+		return slot
+	}
+
+	var key bpfFlowKey
+	var flow bpfFlow
+	drained := make(map[bpfFlowKey]bpfFlow)
+	iterator := flows.Iterate()
+	for iterator.Next(&key, &flow) {
+		if key.Slot == slot {
+			drained[key] = flow
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		slog.Error("[inet] Iterating flows", "Error", err)
+	}
+
+	// deleted after iterating, deleting while iterating a hash map starts the iteration over
+	for key, flow := range drained {
+		if err := flows.Delete(key); err != nil {
+			slog.Error("[inet] Deleting flow", "Error", err)
+		}
+		distributeFlow(key, flow, inetEbpf)
+	}
+	return slot ^ 1
+}
+
+func (inetEbpf *InetEbpf) readLoopback(rd *perf.Reader) {
+	// bpfLoopbackEvent is generated by bpf2go and represents perf event type in eBPF program
+	var event bpfLoopbackEvent
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			slog.Error("[inet] Reading from loopback reader", "Error", err)
+			continue
+		}
+
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			slog.Error("[inet] Parsing loopback perf event", "Error", err)
+			continue
+		}
+
+		distributeLoopback(event, inetEbpf)
+	}
+}
+
 func distribute(event bpfEvent, inet *InetEbpf) {
 	tcpEvent := modules.TCPEvent{
 		Client: modules.Address{
@@ -106,15 +250,92 @@ func distribute(event bpfEvent, inet *InetEbpf) {
 		Server: modules.Address{
 			Addr: intToIP4(event.Daddr),
 			Port: event.Dport},
-		TxB:     event.TxB,
-		RxB:     event.RxB,
-		DeltaUs: event.DeltaUs / 1000}
+		TxB:        event.TxB,
+		RxB:        event.RxB,
+		DeltaUs:    event.DeltaUs / 1000,
+		MaxDeltaUs: event.DeltaUs / 1000,
+		Count:      1,
+		ClientSide: event.Initiator != 0}
+
+	// replace the proxy with the original client conveyed by PROXY protocol header, see ebpf/tc
+	if origin, ok := ebpf_tools.ProxyOrigin(tcpEvent.Client, tcpEvent.Server); ok {
+		ebpf_tools.RemoveProxyOrigin(tcpEvent.Client, tcpEvent.Server)
+		tcpEvent.Proxy = tcpEvent.Client
+		tcpEvent.Client = origin
+		tcpEvent.Proxied = true
+		ebpf_tools.EnrichAddress(&tcpEvent.Proxy)
+	}
+	ebpf_tools.EnrichAddress(&tcpEvent.Client)
+	ebpf_tools.EnrichAddress(&tcpEvent.Server)
+
+	inet.Broker.TCPEvent(tcpEvent)
+}
+
+// summary of connections from a client to a server port, the client port differs between connections and is not kept,
+// connections of trusted proxies are not aggregated, see setTrustedProxies
+func distributeFlow(key bpfFlowKey, flow bpfFlow, inet *InetEbpf) {
+	tcpEvent := modules.TCPEvent{
+		Client: modules.Address{
+			Addr: intToIP4(key.Saddr)},
+		Server: modules.Address{
+			Addr: intToIP4(key.Daddr),
+			Port: key.Dport},
+		TxB:        flow.TxB,
+		RxB:        flow.RxB,
+		DeltaUs:    flow.DeltaUs / 1000,
+		MaxDeltaUs: flow.MaxDeltaUs / 1000,
+		Count:      flow.Count,
+		ClientSide: key.Initiator != 0}
 	ebpf_tools.EnrichAddress(&tcpEvent.Client)
 	ebpf_tools.EnrichAddress(&tcpEvent.Server)
 
 	inet.Broker.TCPEvent(tcpEvent)
 }
 
+// loopback connections are reported by the connecting side only, both ends are in the pod of the connecting process
+func distributeLoopback(event bpfLoopbackEvent, inet *InetEbpf) {
+	loopbackEvent := modules.LoopbackEvent{
+		Client: modules.Address{
+			Addr: intToIP4(event.Saddr),
+			Port: event.Sport},
+		Server: modules.Address{
+			Addr: intToIP4(event.Daddr),
+			Port: event.Dport},
+		Process: modules.Process{
+			Pid:  event.Pid,
+			Comm: cString(event.Comm[:])},
+		TxB:     event.TxB,
+		RxB:     event.RxB,
+		DeltaUs: event.DeltaUs / 1000}
+	uid := podUID(cString(event.Cgroup[:]), cString(event.ParentCgroup[:]))
+	ebpf_tools.EnrichAddressByPodUID(&loopbackEvent.Client, uid)
+	ebpf_tools.EnrichAddressByPodUID(&loopbackEvent.Server, uid)
+
+	inet.Broker.LoopbackEvent(loopbackEvent)
+}
+
+// the process runs in the container cgroup, which is a child of the pod cgroup
+func podUID(cgroups ...string) string {
+	for _, cgroup := range cgroups {
+		if matches := podCgroupRegexp.FindStringSubmatch(cgroup); len(matches) > 1 {
+			return strings.ReplaceAll(matches[1], "_", "-")
+		}
+	}
+	return ""
+}
+
+// NUL terminated C string of the eBPF event
+func cString(chars []int8) string {
+	var str strings.Builder
+	for _, char := range chars {
+		if char == 0 {
+			break
+		}
+		str.WriteByte(byte(char))
+	}
+	return str.String()
+}
+
 func intToIP4(ipNum uint32) string {
 	ip := make(net.IP, 4)
 	binary.LittleEndian.PutUint32(ip, ipNum)
@@ -0,0 +1,139 @@
+package ebpf_inet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/k8spacket/k8spacket/broker"
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBroker struct {
+	broker.IBroker
+	loopbackEvents []modules.LoopbackEvent
+	tcpEvents      []modules.TCPEvent
+}
+
+func (mock *mockBroker) TCPEvent(event modules.TCPEvent) {
+	mock.tcpEvents = append(mock.tcpEvents, event)
+}
+
+func (mock *mockBroker) LoopbackEvent(event modules.LoopbackEvent) {
+	mock.loopbackEvents = append(mock.loopbackEvents, event)
+}
+
+func cChars[T [16]int8 | [128]int8](str string) T {
+	var chars T
+	for i := range len(str) {
+		chars[i] = int8(str[i])
+	}
+	return chars
+}
+
+func TestDistributeLoopback(t *testing.T) {
+
+	defer ebpf_tools.SetK8sInfo(ebpf_tools.K8sInfo())
+	ebpf_tools.SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.11": {Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456", UID: "0d2c1d4e-9e2b-4b1d-8f2a-2a5b7c9d1e3f"},
+	})
+
+	var tests = []struct {
+		scenario     string
+		cgroup       string
+		parentCgroup string
+		want         modules.Address
+	}{
+		{"systemd driver", "cri-containerd-abc.scope", "kubepods-burstable-pod0d2c1d4e_9e2b_4b1d_8f2a_2a5b7c9d1e3f.slice", modules.Address{Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"}},
+		{"cgroupfs driver", "abc", "pod0d2c1d4e-9e2b-4b1d-8f2a-2a5b7c9d1e3f", modules.Address{Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"}},
+		{"unknown pod", "cri-containerd-abc.scope", "kubepods-pod11111111_2222_3333_4444_555555555555.slice", modules.Address{}},
+		{"not a pod", "", "", modules.Address{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			broker := &mockBroker{}
+
+			distributeLoopback(bpfLoopbackEvent{Saddr: 0x0100007f, Daddr: 0x0100007f, Sport: 41000, Dport: 8080, Pid: 10,
+				DeltaUs: 5000, RxB: 20, TxB: 10, Comm: cChars[[16]int8]("envoy"),
+				Cgroup: cChars[[128]int8](test.cgroup), ParentCgroup: cChars[[128]int8](test.parentCgroup)}, &InetEbpf{Broker: broker})
+
+			client, server := test.want, test.want
+			client.Addr, client.Port = "127.0.0.1", 41000
+			server.Addr, server.Port = "127.0.0.1", 8080
+
+			assert.EqualValues(t, []modules.LoopbackEvent{{Client: client, Server: server, Process: modules.Process{Pid: 10, Comm: "envoy"},
+				TxB: 10, RxB: 20, DeltaUs: 5}}, broker.loopbackEvents)
+		})
+	}
+}
+
+func TestDistributeProxied(t *testing.T) {
+
+	defer ebpf_tools.SetK8sInfo(ebpf_tools.K8sInfo())
+	ebpf_tools.SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.5":  {Name: "pod.ingress-1", Namespace: "ingress", WorkloadId: "123"},
+		"10.0.0.11": {Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"},
+	})
+
+	proxy := modules.Address{Addr: "10.0.0.5", Port: 41000}
+	backend := modules.Address{Addr: "10.0.0.11", Port: 8080}
+	ebpf_tools.SetProxyOrigin(proxy, backend, modules.Address{Addr: "192.168.5.7", Port: 56324})
+
+	broker := &mockBroker{}
+	event := bpfEvent{Saddr: 0x0500000a, Daddr: 0x0b00000a, Sport: 41000, Dport: 8080, DeltaUs: 5000, RxB: 20, TxB: 10}
+
+	distribute(event, &InetEbpf{Broker: broker})
+	// the origin is forgotten when the connection is closed
+	distribute(event, &InetEbpf{Broker: broker})
+
+	assert.EqualValues(t, []modules.TCPEvent{
+		{Client: modules.Address{Addr: "192.168.5.7", Port: 56324, Name: "N/A", WorkloadId: "192.168.5.7"},
+			Server: modules.Address{Addr: "10.0.0.11", Port: 8080, Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"},
+			TxB:    10, RxB: 20, DeltaUs: 5, MaxDeltaUs: 5, Count: 1, Proxied: true,
+			Proxy: modules.Address{Addr: "10.0.0.5", Port: 41000, Name: "pod.ingress-1", Namespace: "ingress", WorkloadId: "123"}},
+		{Client: modules.Address{Addr: "10.0.0.5", Port: 41000, Name: "pod.ingress-1", Namespace: "ingress", WorkloadId: "123"},
+			Server: modules.Address{Addr: "10.0.0.11", Port: 8080, Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"},
+			TxB:    10, RxB: 20, DeltaUs: 5, MaxDeltaUs: 5, Count: 1},
+	}, broker.tcpEvents)
+}
+
+func TestDistributeFlow(t *testing.T) {
+
+	defer ebpf_tools.SetK8sInfo(ebpf_tools.K8sInfo())
+	ebpf_tools.SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.5":  {Name: "pod.frontend-1", Namespace: "shop", WorkloadId: "123"},
+		"10.0.0.11": {Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"},
+	})
+
+	broker := &mockBroker{}
+
+	distributeFlow(bpfFlowKey{Saddr: 0x0500000a, Daddr: 0x0b00000a, Dport: 8080, Persistent: 1, Slot: 1, Initiator: 1},
+		bpfFlow{Count: 3, DeltaUs: 9000, MaxDeltaUs: 4000, RxB: 60, TxB: 30}, &InetEbpf{Broker: broker})
+
+	assert.EqualValues(t, []modules.TCPEvent{
+		{Client: modules.Address{Addr: "10.0.0.5", Name: "pod.frontend-1", Namespace: "shop", WorkloadId: "123"},
+			Server: modules.Address{Addr: "10.0.0.11", Port: 8080, Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"},
+			TxB:    30, RxB: 60, DeltaUs: 9, MaxDeltaUs: 4, Count: 3, ClientSide: true},
+	}, broker.tcpEvents)
+}
+
+func TestPersistentDurationUs(t *testing.T) {
+	assert.EqualValues(t, 999, persistentDurationUs(""))
+	assert.EqualValues(t, 999, persistentDurationUs("-1s"))
+	assert.EqualValues(t, 1999, persistentDurationUs("1ms"))
+	assert.EqualValues(t, 1999, persistentDurationUs("1500us"))
+	assert.EqualValues(t, 2000999, persistentDurationUs("2s"))
+}
+
+func TestTrustedProxyKeys(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	_, host, _ := net.ParseCIDR("192.168.1.10/32")
+	_, ipv6, _ := net.ParseCIDR("fd00::/48")
+
+	assert.EqualValues(t, []bpfTrustedProxyKey{{Prefixlen: 8, Addr: 0x0000000a}, {Prefixlen: 32, Addr: 0x0a01a8c0}},
+		trustedProxyKeys([]*net.IPNet{network, ipv6, host}))
+}
@@ -14,6 +14,7 @@ import (
 	ebpf_tc "github.com/k8spacket/k8spacket/ebpf/tc"
 	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
 	k8sclient "github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/security"
 )
 
 type Loader struct {
@@ -30,6 +31,7 @@ func (loader *Loader) Load() {
 	// load inet_sock_set_state ebpf program
 	go loader.inetEbpf.Init()
 	go interfacesRefresher(*loader)
+	go k8sInfoRefresher()
 }
 
 func interfacesRefresher(loader Loader) {
@@ -57,15 +59,46 @@ func interfacesRefresher(loader Loader) {
 			}
 			if refreshK8sInfo {
 				// there are some new workloads in the cluster and need to update info about k8s resources
-				ebpf_tools.K8sInfo = k8sclient.FetchK8SInfo()
+				updateK8sInfo()
 			}
 			currentInterfaces = loader.interfaces
 		}
 	}
 }
 
+// k8s resources are also refreshed every K8S_PACKET_K8S_RESOURCES_REFRESH_PERIOD (1m by default),
+// new interfaces are not found e.g. when workloads are deleted or pods are rescheduled on other nodes
+func k8sInfoRefresher() {
+	var refreshPeriod, err = time.ParseDuration(os.Getenv("K8S_PACKET_K8S_RESOURCES_REFRESH_PERIOD"))
+	if err != nil || refreshPeriod <= 0 {
+		refreshPeriod = time.Minute
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("[k8s-loop] Receive signal, exiting...")
+			return
+		case <-time.After(refreshPeriod):
+			updateK8sInfo()
+		}
+	}
+}
+
+func updateK8sInfo() {
+	if info, err := k8sclient.FetchK8SInfo(); err != nil {
+		slog.Error("[k8s-loop] Cannot get k8s resources, keeping previous ones", "Error", err)
+	} else {
+		ebpf_tools.SetK8sInfo(info)
+	}
+}
+
 // looking for network interfaces on cluster nodes regarding started containers based on the command `ip address`
 func findInterfaces() []string {
+	security.UseFeature(security.FeatureExec)
 	command := os.Getenv("K8S_PACKET_TCP_LISTENER_INTERFACES_COMMAND")
 	cmd := exec.Command("sh", "-c", command)
 	out, err := cmd.Output()
@@ -10,6 +10,8 @@ import (
 
 	ebpf_inet "github.com/k8spacket/k8spacket/ebpf/inet"
 	ebpf_tc "github.com/k8spacket/k8spacket/ebpf/tc"
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	k8sclient "github.com/k8spacket/k8spacket/external/k8s"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -67,3 +69,17 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+func TestK8sInfoRefresher(t *testing.T) {
+
+	os.Setenv("K8S_PACKET_K8S_RESOURCES_REFRESH_PERIOD", "100ms")
+	defer os.Unsetenv("K8S_PACKET_K8S_RESOURCES_REFRESH_PERIOD")
+
+	// refreshed without new interfaces, getting k8s resources is disabled in tests, so there are none
+	ebpf_tools.SetK8sInfo(map[string]k8sclient.IPResourceInfo{"10.0.0.1": {Name: "pod.deleted-1"}})
+	go k8sInfoRefresher()
+
+	assert.Eventually(t, func() bool {
+		return len(ebpf_tools.K8sInfo()) == 0
+	}, time.Second*1, time.Millisecond*100)
+}
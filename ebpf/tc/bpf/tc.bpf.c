@@ -25,6 +25,12 @@
 #define EXTENSION_LIST_MAX_SIZE 100
 #define SUPPORTED_TLS_VERSIONS_MAX_SIZE 8
 
+#define PROXY_V1_SIGNATURE_SIZE 6
+#define PROXY_V2_SIGNATURE_SIZE 12
+#define PROXY_V2_HEADER_SIZE 16
+#define PROXY_V2_LENGTH_OFFSET 14
+#define PROXY_HEADER_MAX_SIZE 108
+
 struct tls_handshake_event {
     u32 saddr;                                              // source IP
     u32 daddr;                                              // destination IP
@@ -54,6 +60,95 @@ struct {
     __uint(max_entries, MAX_ENTRIES);
 } output_events SEC(".maps");
 
+struct proxy_header_event {
+    u32 saddr;                                              // source IP (proxy)
+    u32 daddr;                                              // destination IP (backend)
+    u16 sport;                                              // source port
+    u16 dport;                                              // destination port
+
+    u16 length;                                             // length of PROXY protocol header (network byte order)
+    unsigned char header[PROXY_HEADER_MAX_SIZE];            // PROXY protocol header, v1 is 107 bytes at most, TLVs of v2 are cut
+};
+
+//dummy unused instance declaration of type to not be optimized, lack causes: "Error: collect C types: type name proxy_header_event: not found"
+struct proxy_header_event *unused_proxy_header_event __attribute__((unused));
+
+// PROXY protocol headers are parsed only when trusted proxies are configured, it is set before loading into the kernel
+const volatile bool parse_proxy = false;
+
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, MAX_ENTRIES);
+} proxy_events SEC(".maps");
+
+// set by userspace while the node is under pressure, TLS handshakes are not parsed then
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, u8);
+} throttle SEC(".maps");
+
+// length of PROXY protocol header (v1 or v2) at the beginning of the payload, 0 if there is no header
+static __always_inline u16 proxy_header_length(struct __sk_buff *ctx, int payload_offset)
+{
+    unsigned char signature[PROXY_V2_SIGNATURE_SIZE];
+    if (bpf_skb_load_bytes(ctx, payload_offset, &signature, PROXY_V1_SIGNATURE_SIZE) < 0)
+        return 0;
+
+    // v1 - human-readable header ended by CRLF, e.g. `PROXY TCP4 203.0.113.7 10.0.0.5 56324 443\r\n`
+    if (signature[0] == 'P' && signature[1] == 'R' && signature[2] == 'O' && signature[3] == 'X' && signature[4] == 'Y' && signature[5] == ' ')
+    {
+        u8 c;
+        for (int i = PROXY_V1_SIGNATURE_SIZE; i < PROXY_HEADER_MAX_SIZE; i++) {
+            if (bpf_skb_load_bytes(ctx, payload_offset + i, &c, sizeof(c)) < 0)
+                return 0;
+            if (c == '\n')
+                return i + 1;
+        }
+        return 0;
+    }
+
+    // v2 - binary header, signature \r\n\r\n\0\r\nQUIT\n, version and command, address family, length of addresses and TLVs
+    if (bpf_skb_load_bytes(ctx, payload_offset, &signature, PROXY_V2_SIGNATURE_SIZE) < 0)
+        return 0;
+    if (signature[0] != 0x0D || signature[1] != 0x0A || signature[2] != 0x0D || signature[3] != 0x0A ||
+        signature[4] != 0x00 || signature[5] != 0x0D || signature[6] != 0x0A || signature[7] != 0x51 ||
+        signature[8] != 0x55 || signature[9] != 0x49 || signature[10] != 0x54 || signature[11] != 0x0A)
+        return 0;
+
+    u16 length;
+    if (bpf_skb_load_bytes(ctx, payload_offset + PROXY_V2_LENGTH_OFFSET, &length, sizeof(length)) < 0)
+        return 0;
+    return PROXY_V2_HEADER_SIZE + bpf_ntohs(length);
+}
+
+// send PROXY protocol header to userspace, the original client is parsed there for trusted proxies only
+static __always_inline void output_proxy_header(struct __sk_buff *ctx, struct iphdr *iph, struct tcphdr *tcp, int payload_offset, u16 length)
+{
+    struct proxy_header_event *event = bpf_ringbuf_reserve(&proxy_events, sizeof(struct proxy_header_event), 0);
+    if (!event)
+        return;
+
+    event->saddr = iph->saddr;
+    event->daddr = iph->daddr;
+    event->sport = tcp->source;
+    event->dport = tcp->dest;
+
+    for (int i = 0; i < PROXY_HEADER_MAX_SIZE; i++) {
+        if (i >= length)
+            break;
+        if (bpf_skb_load_bytes(ctx, payload_offset + i, &event->header[i], sizeof(event->header[i])) < 0) {
+            bpf_ringbuf_discard(event, 0);
+            return;
+        }
+    }
+    event->length = bpf_htons(length > PROXY_HEADER_MAX_SIZE ? PROXY_HEADER_MAX_SIZE : length);
+
+    //store event in BPF ringbuf proxy_events map
+    bpf_ringbuf_submit(event, 0);
+}
+
 SEC("tc")
 int tc_filter(struct __sk_buff *ctx)
 {
@@ -93,6 +188,24 @@ int tc_filter(struct __sk_buff *ctx)
     if (payload_offset >= ctx->len)
         return TC_ACT_OK;
 
+    // PROXY protocol header is sent by a proxy (f.e. ingress or load balancer) at the beginning of a connection to a backend
+    u16 proxy_length = proxy_header_length(ctx, payload_offset);
+    if (proxy_length > 0) {
+        if (parse_proxy)
+            output_proxy_header(ctx, iph, tcp, payload_offset, proxy_length);
+
+        // skip the header, TLS handshake follows it when both are sent in the same packet
+        payload_offset += proxy_length;
+        if (payload_offset >= ctx->len)
+            return TC_ACT_OK;
+    }
+
+    // skip parsing of TLS handshakes while throttled, PROXY protocol headers are still reported
+    u32 zero = 0;
+    u8 *throttled = bpf_map_lookup_elem(&throttle, &zero);
+    if (throttled && *throttled)
+        return TC_ACT_OK;
+
     // record type
     u8 record_type;
     bpf_skb_load_bytes(ctx, payload_offset, &record_type, sizeof(record_type));
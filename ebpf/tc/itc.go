@@ -1,5 +1,24 @@
 package ebpf_tc
 
+import "github.com/cilium/ebpf/ringbuf"
+
 type ItcEbpf interface {
 	Init(iface string)
 }
+
+type ITcObjectsLoader interface {
+	Load() (ITcObjects, error)
+}
+
+type ITcObjects interface {
+	ProgramFD() int
+	NewReader() (IRingbufReader, error)
+	NewProxyReader() (IRingbufReader, error)
+	SetThrottled(throttled bool) error
+	Close() error
+}
+
+type IRingbufReader interface {
+	Read() (ringbuf.Record, error)
+	Close() error
+}
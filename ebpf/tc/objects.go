@@ -0,0 +1,60 @@
+package ebpf_tc
+
+import (
+	"github.com/cilium/ebpf/ringbuf"
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+)
+
+type TcObjectsLoader struct {
+	ITcObjectsLoader
+}
+
+type TcObjects struct {
+	ITcObjects
+	objs tcObjects
+}
+
+// Load pre-compiled programs and maps into the kernel.
+// parse_proxy is a read-only constant of the eBPF program, it is set before loading into the kernel
+func (loader *TcObjectsLoader) Load() (ITcObjects, error) {
+	spec, err := loadTc()
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.RewriteConstants(map[string]interface{}{"parse_proxy": ebpf_tools.TrustsProxies()}); err != nil {
+		return nil, err
+	}
+	objects := &TcObjects{}
+	if err := spec.LoadAndAssign(&objects.objs, nil); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// get the file descriptor of the tc_filter program
+func (objects *TcObjects) ProgramFD() int {
+	return objects.objs.tcPrograms.TcFilter.FD()
+}
+
+// create new reader for ringbuf events
+func (objects *TcObjects) NewReader() (IRingbufReader, error) {
+	return ringbuf.NewReader(objects.objs.OutputEvents)
+}
+
+// create new reader for ringbuf PROXY protocol headers
+func (objects *TcObjects) NewProxyReader() (IRingbufReader, error) {
+	return ringbuf.NewReader(objects.objs.ProxyEvents)
+}
+
+// skip parsing of TLS handshakes in the kernel while the node is under pressure
+func (objects *TcObjects) SetThrottled(throttled bool) error {
+	var value uint8
+	if throttled {
+		value = 1
+	}
+	return objects.objs.Throttle.Put(uint32(0), value)
+}
+
+func (objects *TcObjects) Close() error {
+	return objects.objs.Close()
+}
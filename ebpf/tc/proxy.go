@@ -0,0 +1,110 @@
+package ebpf_tc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/ringbuf"
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/modules"
+)
+
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	proxyV2HeaderSize = 16
+	// version 2 and PROXY command, LOCAL command (health checks of the proxy itself) has no original client
+	proxyV2Proxy = 0x21
+	proxyV2TCP4  = 0x11
+	proxyV2TCP6  = 0x21
+)
+
+func readProxy(rd IRingbufReader) {
+	// tcProxyHeaderEvent is generated by bpf2go and represents ringbuf event type in eBPF program
+	var event tcProxyHeaderEvent
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, ringbuf.ErrClosed) {
+				slog.Info("[tc] Received signal, exiting PROXY protocol reader..")
+				return
+			}
+			slog.Error("[tc] Reading from PROXY protocol reader", "Error", err)
+			continue
+		}
+
+		// Parse the ringbuf event into a tcProxyHeaderEvent structure.
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.BigEndian, &event); err != nil {
+			slog.Error("[tc] Parsing ringbuf PROXY protocol event", "Error", err)
+			continue
+		}
+
+		distributeProxy(event)
+	}
+}
+
+// the original client is taken from proxies in K8S_PACKET_TRUSTED_PROXY_CIDRS only, anyone can send a PROXY protocol header
+func distributeProxy(event tcProxyHeaderEvent) {
+	proxy := modules.Address{Addr: intToIP4(event.Saddr), Port: event.Sport}
+	if !ebpf_tools.IsTrustedProxy(proxy.Addr) {
+		return
+	}
+
+	length := min(int(event.Length), len(event.Header))
+	origin, ok := parseProxyHeader(event.Header[:length])
+	if !ok {
+		return
+	}
+	backend := modules.Address{Addr: intToIP4(event.Daddr), Port: event.Dport}
+	ebpf_tools.SetProxyOrigin(proxy, backend, origin)
+}
+
+// source address and port of PROXY protocol header v1 (e.g. `PROXY TCP4 203.0.113.7 10.0.0.5 56324 443\r\n`) or v2
+func parseProxyHeader(header []byte) (modules.Address, bool) {
+	if bytes.HasPrefix(header, proxyV2Signature) {
+		return parseProxyV2Header(header)
+	}
+
+	fields := strings.Fields(string(header))
+	if len(fields) != 6 || fields[0] != "PROXY" || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return modules.Address{}, false
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return modules.Address{}, false
+	}
+	return modules.Address{Addr: ip.String(), Port: uint16(port)}, true
+}
+
+// binary header: signature, version and command, address family, length, source address, destination address,
+// source port, destination port
+func parseProxyV2Header(header []byte) (modules.Address, bool) {
+	if len(header) < proxyV2HeaderSize || header[12] != proxyV2Proxy {
+		return modules.Address{}, false
+	}
+
+	var ipLen int
+	switch header[13] {
+	case proxyV2TCP4:
+		ipLen = net.IPv4len
+	case proxyV2TCP6:
+		ipLen = net.IPv6len
+	default:
+		return modules.Address{}, false
+	}
+
+	addresses := header[proxyV2HeaderSize:]
+	if len(addresses) < 2*ipLen+4 || int(binary.BigEndian.Uint16(header[14:16])) < 2*ipLen+4 {
+		return modules.Address{}, false
+	}
+	return modules.Address{
+		Addr: net.IP(addresses[:ipLen]).String(),
+		Port: binary.BigEndian.Uint16(addresses[2*ipLen:])}, true
+}
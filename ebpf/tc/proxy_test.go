@@ -0,0 +1,76 @@
+package ebpf_tc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"testing"
+
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/stretchr/testify/assert"
+)
+
+func proxyV2Header(command byte, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestParseProxyHeader(t *testing.T) {
+
+	tcp4 := []byte{203, 0, 113, 7, 10, 0, 0, 11, 0xdc, 0x04, 0x01, 0xbb}
+	tcp6 := append(append(make([]byte, 0, 36), []byte{0x20, 0x01, 0x0d, 0xb8, 15: 0x07}...), make([]byte, 16)...)
+	tcp6 = append(tcp6, 0xdc, 0x04, 0x01, 0xbb)
+
+	var tests = []struct {
+		scenario string
+		header   []byte
+		want     modules.Address
+		ok       bool
+	}{
+		{"v1 TCP4", []byte("PROXY TCP4 203.0.113.7 10.0.0.11 56324 443\r\n"), modules.Address{Addr: "203.0.113.7", Port: 56324}, true},
+		{"v1 TCP6", []byte("PROXY TCP6 2001:db8::7 fd00::11 56324 443\r\n"), modules.Address{Addr: "2001:db8::7", Port: 56324}, true},
+		{"v1 UNKNOWN", []byte("PROXY UNKNOWN\r\n"), modules.Address{}, false},
+		{"v1 invalid address", []byte("PROXY TCP4 203.0.113 10.0.0.11 56324 443\r\n"), modules.Address{}, false},
+		{"v1 invalid port", []byte("PROXY TCP4 203.0.113.7 10.0.0.11 70000 443\r\n"), modules.Address{}, false},
+		{"v2 TCP4", proxyV2Header(proxyV2Proxy, proxyV2TCP4, tcp4), modules.Address{Addr: "203.0.113.7", Port: 56324}, true},
+		{"v2 TCP6", proxyV2Header(proxyV2Proxy, proxyV2TCP6, tcp6), modules.Address{Addr: "2001:db8::7", Port: 56324}, true},
+		{"v2 TLVs", proxyV2Header(proxyV2Proxy, proxyV2TCP4, append(tcp4, 0x04, 0x00, 0x01, 0x00)), modules.Address{Addr: "203.0.113.7", Port: 56324}, true},
+		{"v2 LOCAL", proxyV2Header(0x20, proxyV2TCP4, tcp4), modules.Address{}, false},
+		{"v2 UDP", proxyV2Header(proxyV2Proxy, 0x12, tcp4), modules.Address{}, false},
+		{"v2 truncated", proxyV2Header(proxyV2Proxy, proxyV2TCP4, tcp4)[:20], modules.Address{}, false},
+		{"no header", []byte{0x16, 0x03, 0x01}, modules.Address{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			got, ok := parseProxyHeader(test.header)
+			assert.EqualValues(t, test.ok, ok)
+			assert.EqualValues(t, test.want, got)
+		})
+	}
+}
+
+func TestReadProxy(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	header := "PROXY TCP4 203.0.113.7 10.0.0.11 56324 443\r\n"
+	event := tcProxyHeaderEvent{Saddr: 0x0a000005, Daddr: 0x0a00000b, Sport: 41000, Dport: 443, Length: uint16(len(header))}
+	copy(event.Header[:], header)
+
+	var record bytes.Buffer
+	binary.Write(&record, binary.BigEndian, event)
+
+	readProxy(&mockReader{records: [][]byte{{0x01}, record.Bytes()}})
+
+	assert.Contains(t, str.String(), "[tc] Parsing ringbuf PROXY protocol event")
+	assert.Contains(t, str.String(), "[tc] Received signal, exiting PROXY protocol reader..")
+
+	// K8S_PACKET_TRUSTED_PROXY_CIDRS is empty, the origin from an untrusted proxy is ignored
+	_, ok := ebpf_tools.ProxyOrigin(modules.Address{Addr: "10.0.0.5", Port: 41000}, modules.Address{Addr: "10.0.0.11", Port: 443})
+	assert.EqualValues(t, false, ok)
+}
@@ -5,42 +5,146 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/k8spacket/k8spacket/broker"
 	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	netlinkclient "github.com/k8spacket/k8spacket/external/netlink"
 	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/security"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 )
 
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go tc ./bpf/tc.bpf.c
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -type proxy_header_event tc ./bpf/tc.bpf.c
 
 type TcEbpf struct {
-	Broker broker.IBroker
+	Broker  broker.IBroker
+	Netlink netlinkclient.INetlink
+	Loader  ITcObjectsLoader
+	// programs loaded for all network interfaces, they are throttled together
+	objects   []ITcObjects
+	throttled bool
+	mutex     sync.Mutex
 }
 
+// delay between subsequent attempts of attaching the program to the network interface
+var attachRetryDelay = time.Second
+
 func (tcEbpf *TcEbpf) Init(iface string) {
 
+	security.UseFeature(security.FeatureEbpf)
+	security.UseFeature(security.FeatureTc)
+
 	// Load pre-compiled programs and maps into the kernel.
-	objs := tcObjects{}
-	if err := loadTcObjects(&objs, nil); err != nil {
+	objs, err := tcEbpf.Loader.Load()
+	if err != nil {
 		slog.Error("[tc] Loading objects", "Error", err)
+		return
 	}
 	defer objs.Close()
 
-	// get the file descriptor of the tc_filter program
-	progFd := objs.tcPrograms.TcFilter.FD()
+	if err := tcEbpf.attachWithRetry(iface, objs.ProgramFD()); err != nil {
+		slog.Error("[tc] Cannot attach program", "interface", iface, "Error", err)
+		return
+	}
+
+	tcEbpf.register(objs)
+	defer tcEbpf.unregister(objs)
+
+	// create new reader for ringbuf events
+	rd, err := objs.NewReader()
+	if err != nil {
+		slog.Error("[tc] Creating perf event reader", "Error", err)
+		return
+	}
+	defer rd.Close()
+
+	go tcEbpf.read(rd)
+
+	if ebpf_tools.TrustsProxies() {
+		// create new reader for ringbuf PROXY protocol headers
+		prd, err := objs.NewProxyReader()
+		if err != nil {
+			slog.Error("[tc] Creating PROXY protocol reader", "Error", err)
+		} else {
+			defer prd.Close()
+			go readProxy(prd)
+		}
+	}
+
+	// graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+
+	slog.Info("[tc] Closed gracefully")
+}
+
+// programs of interfaces attached while the node is under pressure start throttled
+func (tcEbpf *TcEbpf) register(objs ITcObjects) {
+	tcEbpf.mutex.Lock()
+	defer tcEbpf.mutex.Unlock()
+	tcEbpf.objects = append(tcEbpf.objects, objs)
+	if tcEbpf.throttled {
+		if err := objs.SetThrottled(true); err != nil {
+			slog.Error("[tc] Cannot throttle program", "Error", err)
+		}
+	}
+}
+
+func (tcEbpf *TcEbpf) unregister(objs ITcObjects) {
+	tcEbpf.mutex.Lock()
+	defer tcEbpf.mutex.Unlock()
+	tcEbpf.objects = slices.DeleteFunc(tcEbpf.objects, func(o ITcObjects) bool { return o == objs })
+}
+
+// TLS handshakes are not parsed by the tc programs while the node is under pressure (K8S_PACKET_PRESSURE_THROTTLING_ENABLED)
+func (tcEbpf *TcEbpf) Throttle(throttled bool) {
+	tcEbpf.mutex.Lock()
+	defer tcEbpf.mutex.Unlock()
+	tcEbpf.throttled = throttled
+	for _, objs := range tcEbpf.objects {
+		if err := objs.SetThrottled(throttled); err != nil {
+			slog.Error("[tc] Cannot throttle program", "Error", err)
+		}
+	}
+}
+
+// network interfaces of new pods may not be fully set up yet, so attaching is retried
+// K8S_PACKET_TCP_LISTENER_ATTACH_RETRIES times (3 by default)
+func (tcEbpf *TcEbpf) attachWithRetry(iface string, progFd int) error {
+	retries, err := strconv.Atoi(os.Getenv("K8S_PACKET_TCP_LISTENER_ATTACH_RETRIES"))
+	if err != nil || retries < 0 {
+		retries = 3
+	}
+
+	err = tcEbpf.attach(iface, progFd)
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		slog.Warn("[tc] Retrying attach", "interface", iface, "attempt", attempt, "Error", err)
+		time.Sleep(attachRetryDelay)
+		err = tcEbpf.attach(iface, progFd)
+	}
+	return err
+}
+
+func (tcEbpf *TcEbpf) attach(iface string, progFd int) error {
 
 	// get link device by name (network interface name)
-	link, err := netlink.LinkByName(iface)
+	link, err := tcEbpf.Netlink.LinkByName(iface)
 	if err != nil {
-		slog.Error("[tc] Cannot find network intefrace", "interface", iface, "Error", err)
+		return fmt.Errorf("cannot find network interface: %w", err)
 	}
 
 	// qdisc clsact - queueing discipline (qdisc) parent of ingress and egress filters
@@ -56,63 +160,26 @@ func (tcEbpf *TcEbpf) Init(iface string) {
 	}
 
 	// try to delete previous added clsact qdisc on specific network interface, equivalent `tc qdisc del dev {{iface}} clsact`
-	if err := netlink.QdiscDel(qdisc); err != nil {
+	if err := tcEbpf.Netlink.QdiscDel(qdisc); err != nil {
 		slog.Error("[tc] Cannot del clsact qdisc", "Error", err)
 	}
 
 	// add clsact qdisc on specific network interface, equivalent `tc qdisc add dev {{iface}} clsact`
 	// check `qdisc show dev {{iface}}`
-	if err := netlink.QdiscAdd(qdisc); err != nil {
-		slog.Error("[tc] Cannot add clsact qdisc", "Error", err)
+	if err := tcEbpf.Netlink.QdiscAdd(qdisc); err != nil {
+		return fmt.Errorf("cannot add clsact qdisc: %w", err)
 	}
 
 	// add ingress filter
-	addFilter(link, progFd, netlink.HANDLE_MIN_INGRESS)
-
-	// add egress filter
-	addFilter(link, progFd, netlink.HANDLE_MIN_EGRESS)
-
-	// create new reader for ringbuf events
-	rd, err := ringbuf.NewReader(objs.OutputEvents)
-	if err != nil {
-		slog.Error("[tc] Creating perf event reader", "Error", err)
+	if err := tcEbpf.addFilter(link, progFd, netlink.HANDLE_MIN_INGRESS); err != nil {
+		return err
 	}
-	defer rd.Close()
 
-	go func() {
-		// tcTlsHandshakeEvent is generated by bpf2go and represents ringbuf event type in eBPF program
-		var event tcTlsHandshakeEvent
-		for {
-			record, err := rd.Read()
-			if err != nil {
-				if errors.Is(err, ringbuf.ErrClosed) {
-					slog.Info("[tc] Received signal, exiting..")
-					return
-				}
-				slog.Error("[tc] Reading from reader", "Error", err)
-				continue
-			}
-
-			// Parse the ringbuf event into a tcTlsHandshakeEvent structure.
-			if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.BigEndian, &event); err != nil {
-				slog.Error("[tc] Parsing ringbuf event", "Error", err)
-				continue
-			}
-
-			distribute(event, tcEbpf)
-		}
-	}()
-
-	// graceful shutdown
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
-	<-ctx.Done()
-
-	slog.Info("[tc] Closed gracefully")
+	// add egress filter
+	return tcEbpf.addFilter(link, progFd, netlink.HANDLE_MIN_EGRESS)
 }
 
-func addFilter(link netlink.Link, programFD int, parent uint32) {
+func (tcEbpf *TcEbpf) addFilter(link netlink.Link, programFD int, parent uint32) error {
 
 	// filter attrs
 	filterAttrs := netlink.FilterAttrs{
@@ -133,8 +200,33 @@ func addFilter(link netlink.Link, programFD int, parent uint32) {
 
 	// add ingress/egress filter, equivalent `tc filter add dev {{iface}} [ingress|egress]`
 	// check `tc filter show dev {{iface}} [ingress|egress]`
-	if err := netlink.FilterAdd(filter); err != nil {
-		slog.Error("[tc] Cannot attach bpf object to filter", "Error", err)
+	if err := tcEbpf.Netlink.FilterAdd(filter); err != nil {
+		return fmt.Errorf("cannot attach bpf object to filter: %w", err)
+	}
+	return nil
+}
+
+func (tcEbpf *TcEbpf) read(rd IRingbufReader) {
+	// tcTlsHandshakeEvent is generated by bpf2go and represents ringbuf event type in eBPF program
+	var event tcTlsHandshakeEvent
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, ringbuf.ErrClosed) {
+				slog.Info("[tc] Received signal, exiting..")
+				return
+			}
+			slog.Error("[tc] Reading from reader", "Error", err)
+			continue
+		}
+
+		// Parse the ringbuf event into a tcTlsHandshakeEvent structure.
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.BigEndian, &event); err != nil {
+			slog.Error("[tc] Parsing ringbuf event", "Error", err)
+			continue
+		}
+
+		distribute(event, tcEbpf)
 	}
 }
 
@@ -167,8 +259,17 @@ func distribute(event tcTlsHandshakeEvent, tc *TcEbpf) {
 		ServerName:     string(event.ServerName[:serverNameLen]),
 		UsedTlsVersion: event.UsedTlsVersion,
 		UsedCipher:     event.UsedCipher}
+
+	// replace the proxy with the original client conveyed by PROXY protocol header, it is removed when the connection is closed
+	if origin, ok := ebpf_tools.ProxyOrigin(tlsEvent.Client, tlsEvent.Server); ok {
+		tlsEvent.Proxy = tlsEvent.Client
+		tlsEvent.Client = origin
+		tlsEvent.Proxied = true
+		ebpf_tools.EnrichAddress(&tlsEvent.Proxy)
+	}
 	ebpf_tools.EnrichAddress(&tlsEvent.Client)
 	ebpf_tools.EnrichAddress(&tlsEvent.Server)
+	ebpf_tools.EnrichAddressByServerName(&tlsEvent.Server, tlsEvent.ServerName)
 	tc.Broker.TLSEvent(tlsEvent)
 }
 
@@ -12,6 +12,16 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type tcProxyHeaderEvent struct {
+	Saddr  uint32
+	Daddr  uint32
+	Sport  uint16
+	Dport  uint16
+	Length uint16
+	Header [108]uint8
+	_      [2]byte
+}
+
 type tcTlsHandshakeEvent struct {
 	Saddr             uint32
 	Daddr             uint32
@@ -79,6 +89,8 @@ type tcProgramSpecs struct {
 type tcMapSpecs struct {
 	Events       *ebpf.MapSpec `ebpf:"events"`
 	OutputEvents *ebpf.MapSpec `ebpf:"output_events"`
+	ProxyEvents  *ebpf.MapSpec `ebpf:"proxy_events"`
+	Throttle     *ebpf.MapSpec `ebpf:"throttle"`
 }
 
 // tcObjects contains all objects after they have been loaded into the kernel.
@@ -102,12 +114,16 @@ func (o *tcObjects) Close() error {
 type tcMaps struct {
 	Events       *ebpf.Map `ebpf:"events"`
 	OutputEvents *ebpf.Map `ebpf:"output_events"`
+	ProxyEvents  *ebpf.Map `ebpf:"proxy_events"`
+	Throttle     *ebpf.Map `ebpf:"throttle"`
 }
 
 func (m *tcMaps) Close() error {
 	return _TcClose(
 		m.Events,
 		m.OutputEvents,
+		m.ProxyEvents,
+		m.Throttle,
 	)
 }
 
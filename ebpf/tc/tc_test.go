@@ -0,0 +1,286 @@
+package ebpf_tc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/k8spacket/k8spacket/broker"
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+type mockNetlink struct {
+	linkErr, qdiscDelErr, qdiscAddErr, filterAddErr error
+	failures                                        int
+	linkCalls, qdiscAddCalls                        int
+	filterParents                                   []uint32
+}
+
+func (mock *mockNetlink) LinkByName(name string) (netlink.Link, error) {
+	mock.linkCalls++
+	if mock.linkCalls <= mock.failures {
+		return nil, errors.New("link not found")
+	}
+	if mock.linkErr != nil {
+		return nil, mock.linkErr
+	}
+	return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name, Index: 7}}, nil
+}
+
+func (mock *mockNetlink) QdiscDel(qdisc netlink.Qdisc) error {
+	return mock.qdiscDelErr
+}
+
+func (mock *mockNetlink) QdiscAdd(qdisc netlink.Qdisc) error {
+	mock.qdiscAddCalls++
+	return mock.qdiscAddErr
+}
+
+func (mock *mockNetlink) FilterAdd(filter netlink.Filter) error {
+	mock.filterParents = append(mock.filterParents, filter.Attrs().Parent)
+	return mock.filterAddErr
+}
+
+type mockObjectsLoader struct {
+	objects *mockObjects
+	err     error
+}
+
+func (mock *mockObjectsLoader) Load() (ITcObjects, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	return mock.objects, nil
+}
+
+type mockObjects struct {
+	readerErr error
+	closed    bool
+	throttled bool
+}
+
+func (mock *mockObjects) ProgramFD() int {
+	return 3
+}
+
+func (mock *mockObjects) NewReader() (IRingbufReader, error) {
+	return nil, mock.readerErr
+}
+
+func (mock *mockObjects) NewProxyReader() (IRingbufReader, error) {
+	return nil, mock.readerErr
+}
+
+func (mock *mockObjects) SetThrottled(throttled bool) error {
+	mock.throttled = throttled
+	return nil
+}
+
+func (mock *mockObjects) Close() error {
+	mock.closed = true
+	return nil
+}
+
+type mockReader struct {
+	records [][]byte
+}
+
+func (mock *mockReader) Read() (ringbuf.Record, error) {
+	if len(mock.records) == 0 {
+		return ringbuf.Record{}, ringbuf.ErrClosed
+	}
+	record := ringbuf.Record{RawSample: mock.records[0]}
+	mock.records = mock.records[1:]
+	return record, nil
+}
+
+func (mock *mockReader) Close() error {
+	return nil
+}
+
+type mockBroker struct {
+	broker.IBroker
+	events []modules.TLSEvent
+}
+
+func (mock *mockBroker) TLSEvent(event modules.TLSEvent) {
+	mock.events = append(mock.events, event)
+}
+
+func TestAttach(t *testing.T) {
+
+	var tests = []struct {
+		scenario      string
+		netlink       *mockNetlink
+		qdiscAddCalls int
+		filterParents []uint32
+		err           string
+	}{
+		{"ok", &mockNetlink{}, 1, []uint32{netlink.HANDLE_MIN_INGRESS, netlink.HANDLE_MIN_EGRESS}, ""},
+		{"no previous qdisc", &mockNetlink{qdiscDelErr: errors.New("no such file")}, 1, []uint32{netlink.HANDLE_MIN_INGRESS, netlink.HANDLE_MIN_EGRESS}, ""},
+		{"link error", &mockNetlink{linkErr: errors.New("link not found")}, 0, nil, "cannot find network interface: link not found"},
+		{"qdisc add error", &mockNetlink{qdiscAddErr: errors.New("exists")}, 1, nil, "cannot add clsact qdisc: exists"},
+		{"filter add error", &mockNetlink{filterAddErr: errors.New("invalid")}, 1, []uint32{netlink.HANDLE_MIN_INGRESS}, "cannot attach bpf object to filter: invalid"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			tcEbpf := &TcEbpf{Netlink: test.netlink}
+
+			err := tcEbpf.attach("eth0", 3)
+
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+			assert.EqualValues(t, test.qdiscAddCalls, test.netlink.qdiscAddCalls)
+			assert.EqualValues(t, test.filterParents, test.netlink.filterParents)
+		})
+	}
+}
+
+func TestAttachWithRetry(t *testing.T) {
+
+	attachRetryDelay = 0
+	defer os.Unsetenv("K8S_PACKET_TCP_LISTENER_ATTACH_RETRIES")
+
+	var tests = []struct {
+		scenario  string
+		retries   string
+		failures  int
+		linkCalls int
+		err       bool
+	}{
+		{"succeeded at first", "", 0, 1, false},
+		{"succeeded after retries", "", 3, 4, false},
+		{"retries exceeded", "", 4, 4, true},
+		{"retries disabled", "0", 1, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			os.Setenv("K8S_PACKET_TCP_LISTENER_ATTACH_RETRIES", test.retries)
+
+			mockNetlink := &mockNetlink{failures: test.failures}
+			tcEbpf := &TcEbpf{Netlink: mockNetlink}
+
+			err := tcEbpf.attachWithRetry("eth0", 3)
+
+			assert.EqualValues(t, test.err, err != nil)
+			assert.EqualValues(t, test.linkCalls, mockNetlink.linkCalls)
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+
+	attachRetryDelay = 0
+	os.Setenv("K8S_PACKET_TCP_LISTENER_ATTACH_RETRIES", "0")
+	defer os.Unsetenv("K8S_PACKET_TCP_LISTENER_ATTACH_RETRIES")
+
+	var tests = []struct {
+		scenario string
+		netlink  *mockNetlink
+		loader   *mockObjectsLoader
+		err      string
+	}{
+		{"loading error", &mockNetlink{}, &mockObjectsLoader{err: errors.New("not permitted")}, "[tc] Loading objects"},
+		{"attach error", &mockNetlink{linkErr: errors.New("link not found")}, &mockObjectsLoader{objects: &mockObjects{}}, "[tc] Cannot attach program"},
+		{"reader error", &mockNetlink{}, &mockObjectsLoader{objects: &mockObjects{readerErr: errors.New("bad map")}}, "[tc] Creating perf event reader"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			var str bytes.Buffer
+			slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+			tcEbpf := &TcEbpf{Netlink: test.netlink, Loader: test.loader}
+
+			tcEbpf.Init("eth0")
+
+			assert.Contains(t, str.String(), test.err)
+			if test.loader.objects != nil {
+				assert.True(t, test.loader.objects.closed)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+
+	tcEbpf := &TcEbpf{}
+	first, second := &mockObjects{}, &mockObjects{}
+
+	tcEbpf.register(first)
+	tcEbpf.Throttle(true)
+	assert.True(t, first.throttled)
+
+	// attached while throttled
+	tcEbpf.register(second)
+	assert.True(t, second.throttled)
+
+	tcEbpf.unregister(first)
+	tcEbpf.Throttle(false)
+	assert.True(t, first.throttled)
+	assert.False(t, second.throttled)
+}
+
+func TestRead(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	event := tcTlsHandshakeEvent{Saddr: 0x0a000001, Daddr: 0x0a000002, Sport: 40000, Dport: 443, ServerNameLength: 4, UsedTlsVersion: 0x0304}
+	copy(event.ServerName[:], "test")
+
+	var record bytes.Buffer
+	binary.Write(&record, binary.BigEndian, event)
+
+	mockBroker := &mockBroker{}
+	tcEbpf := &TcEbpf{Broker: mockBroker}
+
+	tcEbpf.read(&mockReader{records: [][]byte{{0x01}, record.Bytes()}})
+
+	assert.Contains(t, str.String(), "[tc] Parsing ringbuf event")
+	assert.Contains(t, str.String(), "[tc] Received signal, exiting..")
+	assert.Len(t, mockBroker.events, 1)
+	assert.EqualValues(t, "10.0.0.1", mockBroker.events[0].Client.Addr)
+	assert.EqualValues(t, "10.0.0.2", mockBroker.events[0].Server.Addr)
+	assert.EqualValues(t, uint16(443), mockBroker.events[0].Server.Port)
+	assert.EqualValues(t, "test", mockBroker.events[0].ServerName)
+}
+
+func TestDistributeProxied(t *testing.T) {
+
+	proxy := modules.Address{Addr: "10.0.0.5", Port: 41000}
+	backend := modules.Address{Addr: "10.0.0.11", Port: 443}
+	ebpf_tools.SetProxyOrigin(proxy, backend, modules.Address{Addr: "192.168.5.7", Port: 56324})
+	defer ebpf_tools.RemoveProxyOrigin(proxy, backend)
+
+	mockBroker := &mockBroker{}
+
+	distribute(tcTlsHandshakeEvent{Saddr: 0x0a000005, Daddr: 0x0a00000b, Sport: 41000, Dport: 443, UsedTlsVersion: 0x0304}, &TcEbpf{Broker: mockBroker})
+
+	assert.Len(t, mockBroker.events, 1)
+	assert.EqualValues(t, true, mockBroker.events[0].Proxied)
+	assert.EqualValues(t, "192.168.5.7", mockBroker.events[0].Client.Addr)
+	assert.EqualValues(t, uint16(56324), mockBroker.events[0].Client.Port)
+	assert.EqualValues(t, "10.0.0.5", mockBroker.events[0].Proxy.Addr)
+	assert.EqualValues(t, uint16(41000), mockBroker.events[0].Proxy.Port)
+	assert.EqualValues(t, "10.0.0.11", mockBroker.events[0].Server.Addr)
+
+	// kept for the TCP summary of the connection
+	_, ok := ebpf_tools.ProxyOrigin(proxy, backend)
+	assert.EqualValues(t, true, ok)
+}
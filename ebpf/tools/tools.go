@@ -4,6 +4,10 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	k8sclient "github.com/k8spacket/k8spacket/external/k8s"
 	"github.com/k8spacket/k8spacket/modules"
@@ -13,14 +17,175 @@ import (
 
 var reverseLookupMap = make(map[string]string)
 
-var K8sInfo = make(map[string]k8sclient.IPResourceInfo)
+// k8s resources by IP with their indexes, replaced at once by SetK8sInfo while events are enriched concurrently
+type k8sResources struct {
+	byIP map[string]k8sclient.IPResourceInfo
+	// services by {{namespace}}/{{name}}
+	services map[string]k8sclient.IPResourceInfo
+	// pods by UID
+	pods map[string]k8sclient.IPResourceInfo
+}
+
+var resources atomic.Pointer[k8sResources]
+
+var serviceDomains = parseServiceDomains(os.Getenv("K8S_PACKET_TLS_SERVICE_DOMAINS"))
+
+// pod and service CIDRs of the cluster, separated by comma, e.g. `100.64.0.0/10,fd00::/48`
+var clusterCIDRs = parseCIDRs(os.Getenv("K8S_PACKET_CLUSTER_CIDRS"))
+
+// proxies (f.e. ingress controllers or load balancers) allowed to convey the original client by PROXY protocol,
+// separated by comma, e.g. `10.0.0.0/8,192.168.1.10/32`, PROXY protocol headers are not parsed when it is empty
+var trustedProxyCIDRs = parseCIDRs(os.Getenv("K8S_PACKET_TRUSTED_PROXY_CIDRS"))
+
+// original clients of proxied connections by {{proxy}}->{{backend}} address, they are removed when a connection is closed,
+// K8S_PACKET_TRUSTED_PROXY_CACHE_SIZE (10000 by default) bounds connections whose close was missed
+var proxyOrigins = make(map[string]modules.Address)
+var proxyOriginsMutex sync.Mutex
+var proxyOriginsSize = cacheSize(os.Getenv("K8S_PACKET_TRUSTED_PROXY_CACHE_SIZE"))
+
+func SetK8sInfo(info map[string]k8sclient.IPResourceInfo) {
+	index := make(map[string]k8sclient.IPResourceInfo)
+	podIndex := make(map[string]k8sclient.IPResourceInfo)
+	for _, resource := range info {
+		if strings.HasPrefix(resource.Name, "svc.") {
+			index[resource.Namespace+"/"+resource.Name] = resource
+		}
+		if resource.UID != "" {
+			podIndex[resource.UID] = resource
+		}
+	}
+	resources.Store(&k8sResources{byIP: info, services: index, pods: podIndex})
+}
+
+// k8s resources by IP from the last refresh, shared with other readers, so it must not be modified
+func K8sInfo() map[string]k8sclient.IPResourceInfo {
+	return currentResources().byIP
+}
+
+// empty until the first refresh, reading nil maps is safe
+func currentResources() *k8sResources {
+	if current := resources.Load(); current != nil {
+		return current
+	}
+	return &k8sResources{}
+}
+
+func parseServiceDomains(value string) []string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return []string{"svc.cluster.local"}
+	}
+	return domains
+}
 
 func EnrichAddress(addr *modules.Address) {
-	addr.Name = K8sInfo[addr.Addr].Name
+	info := K8sInfo()[addr.Addr]
+	addr.Name = info.Name
 	if addr.Name == "" {
 		addr.Name = reverseLookup(addr.Addr)
 	}
-	addr.Namespace = K8sInfo[addr.Addr].Namespace
+	addr.Namespace = info.Namespace
+	addr.WorkloadId = WorkloadId(addr.Addr)
+}
+
+// external addresses are not k8s workloads, the IP identifies them
+func WorkloadId(ip string) string {
+	if workloadId := K8sInfo()[ip].WorkloadId; workloadId != "" {
+		return workloadId
+	}
+	return ip
+}
+
+// attribute TLS traffic to a cluster Service when SNI is its DNS name, e.g. {{service}}.{{namespace}}.svc.cluster.local
+// it helps when the destination IP is a LoadBalancer or an ingress VIP which is unknown for the k8s resources map
+func EnrichAddressByServerName(addr *modules.Address, serverName string) {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+
+	for _, domain := range serviceDomains {
+		if !strings.HasSuffix(serverName, "."+domain) {
+			continue
+		}
+		// expected {{service}}.{{namespace}} before the domain
+		labels := strings.Split(strings.TrimSuffix(serverName, "."+domain), ".")
+		if len(labels) != 2 {
+			continue
+		}
+		if info, ok := currentResources().services[labels[1]+"/svc."+labels[0]]; ok {
+			addr.Name = info.Name
+			addr.Namespace = info.Namespace
+			addr.WorkloadId = info.WorkloadId
+			return
+		}
+	}
+}
+
+// attribute a connection inside a pod (e.g. over the loopback interface) to the pod by its UID,
+// the address is kept as is when the pod is unknown (anymore) to the k8s info
+func EnrichAddressByPodUID(addr *modules.Address, uid string) {
+	if info, ok := currentResources().pods[uid]; ok && uid != "" {
+		addr.Name = info.Name
+		addr.Namespace = info.Namespace
+		addr.WorkloadId = info.WorkloadId
+	}
+}
+
+func TrustsProxies() bool {
+	return len(trustedProxyCIDRs) > 0
+}
+
+func TrustedProxyCIDRs() []*net.IPNet {
+	return trustedProxyCIDRs
+}
+
+func IsTrustedProxy(ip string) bool {
+	ipAddress := net.ParseIP(ip)
+	for _, cidr := range trustedProxyCIDRs {
+		if ipAddress != nil && cidr.Contains(ipAddress) {
+			return true
+		}
+	}
+	return false
+}
+
+// remember the original client conveyed by a trusted proxy for the connection from the proxy to the backend
+func SetProxyOrigin(proxy modules.Address, backend modules.Address, origin modules.Address) {
+	proxyOriginsMutex.Lock()
+	defer proxyOriginsMutex.Unlock()
+	if len(proxyOrigins) >= proxyOriginsSize {
+		proxyOrigins = make(map[string]modules.Address)
+	}
+	proxyOrigins[proxyOriginKey(proxy, backend)] = origin
+}
+
+func ProxyOrigin(proxy modules.Address, backend modules.Address) (modules.Address, bool) {
+	proxyOriginsMutex.Lock()
+	defer proxyOriginsMutex.Unlock()
+	origin, ok := proxyOrigins[proxyOriginKey(proxy, backend)]
+	return origin, ok
+}
+
+func RemoveProxyOrigin(proxy modules.Address, backend modules.Address) {
+	proxyOriginsMutex.Lock()
+	defer proxyOriginsMutex.Unlock()
+	delete(proxyOrigins, proxyOriginKey(proxy, backend))
+}
+
+func proxyOriginKey(proxy modules.Address, backend modules.Address) string {
+	return net.JoinHostPort(proxy.Addr, strconv.Itoa(int(proxy.Port))) + "->" + net.JoinHostPort(backend.Addr, strconv.Itoa(int(backend.Port)))
+}
+
+func cacheSize(value string) int {
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return 10000
+	}
+	return size
 }
 
 // try to find organization name and (if GeoLite2 Free Geolocation Data enabled) country and city by external IP
@@ -58,6 +223,32 @@ func reverseLookup(ip string) string {
 	return reverseLookupMap[ip]
 }
 
+// CIDRs separated by comma, invalid ones are skipped
+func parseCIDRs(value string) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, cidr := range strings.Split(value, ",") {
+		if _, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr)); err == nil {
+			cidrs = append(cidrs, ipNet)
+		}
+	}
+	return cidrs
+}
+
+// IP outside of private, loopback, link-local and multicast ranges and the cluster CIDRs (K8S_PACKET_CLUSTER_CIDRS)
+func IsExternalIP(ip string) bool {
+	ipAddress := net.ParseIP(ip)
+	if ipAddress == nil || ipAddress.IsPrivate() || ipAddress.IsLoopback() || ipAddress.IsLinkLocalUnicast() ||
+		ipAddress.IsLinkLocalMulticast() || ipAddress.IsMulticast() || ipAddress.IsUnspecified() {
+		return false
+	}
+	for _, cidr := range clusterCIDRs {
+		if cidr.Contains(ipAddress) {
+			return false
+		}
+	}
+	return true
+}
+
 // Check if an IP is private.
 func privateIPCheck(ip string) bool {
 	ipAddress := net.ParseIP(ip)
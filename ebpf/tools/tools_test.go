@@ -1,17 +1,18 @@
 package ebpf_tools
 
 import (
-	"os"
+	"net"
 	"testing"
 
+	k8sclient "github.com/k8spacket/k8spacket/external/k8s"
 	"github.com/k8spacket/k8spacket/modules"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestEnrichAddress(t *testing.T) {
 
-	os.Setenv("K8S_PACKET_REVERSE_WHOIS_REGEXP", "(?:OrgName:|org-name:)\\s*(.*)")
-	os.Setenv("K8S_PACKET_REVERSE_GEOIP2_DB_PATH", "../../tests/GeoLite2-City-Test.mmdb")
+	t.Setenv("K8S_PACKET_REVERSE_WHOIS_REGEXP", "(?:OrgName:|org-name:)\\s*(.*)")
+	t.Setenv("K8S_PACKET_REVERSE_GEOIP2_DB_PATH", "../../tests/GeoLite2-City-Test.mmdb")
 
 	address := modules.Address{Addr: "89.160.20.129"}
 
@@ -33,6 +34,128 @@ func TestEnrichAddress(t *testing.T) {
 
 }
 
+func TestEnrichAddressWorkloadId(t *testing.T) {
+
+	defer SetK8sInfo(K8sInfo())
+	SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.11": {Name: "pod.backend-5d8f7b-x2k4p", Namespace: "shop", WorkloadId: "456"},
+	})
+
+	address := modules.Address{Addr: "10.0.0.11"}
+
+	EnrichAddress(&address)
+
+	assert.EqualValues(t, modules.Address{Addr: "10.0.0.11", Name: "pod.backend-5d8f7b-x2k4p", Namespace: "shop", WorkloadId: "456"}, address)
+
+	address = modules.Address{Addr: "10.0.0.12"}
+
+	EnrichAddress(&address)
+
+	assert.EqualValues(t, "10.0.0.12", address.WorkloadId)
+
+}
+
+func TestEnrichAddressByServerName(t *testing.T) {
+
+	defer SetK8sInfo(K8sInfo())
+	SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.10": {Name: "svc.backend", Namespace: "shop", WorkloadId: "123"},
+		"10.0.0.11": {Name: "pod.backend", Namespace: "shop", WorkloadId: "456"},
+	})
+
+	defer func(domains []string) { serviceDomains = domains }(serviceDomains)
+
+	var tests = []struct {
+		domains    string
+		serverName string
+		want       modules.Address
+	}{
+		{"", "backend.shop.svc.cluster.local", modules.Address{Addr: "1.2.3.4", Name: "svc.backend", Namespace: "shop", WorkloadId: "123"}},
+		{"", "Backend.Shop.svc.cluster.local.", modules.Address{Addr: "1.2.3.4", Name: "svc.backend", Namespace: "shop", WorkloadId: "123"}},
+		{"", "unknown.shop.svc.cluster.local", modules.Address{Addr: "1.2.3.4", Name: "lb"}},
+		{"", "backend.shop.example.com", modules.Address{Addr: "1.2.3.4", Name: "lb"}},
+		{"svc.cluster.local,example.com", "backend.shop.example.com", modules.Address{Addr: "1.2.3.4", Name: "svc.backend", Namespace: "shop", WorkloadId: "123"}},
+		{"example.com", "www.backend.shop.example.com", modules.Address{Addr: "1.2.3.4", Name: "lb"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.serverName, func(t *testing.T) {
+			serviceDomains = parseServiceDomains(test.domains)
+
+			address := modules.Address{Addr: "1.2.3.4", Name: "lb"}
+
+			EnrichAddressByServerName(&address, test.serverName)
+
+			assert.EqualValues(t, test.want, address)
+		})
+	}
+
+}
+
+func TestEnrichAddressByPodUID(t *testing.T) {
+
+	defer SetK8sInfo(K8sInfo())
+	SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.10": {Name: "svc.backend", Namespace: "shop", WorkloadId: "123"},
+		"10.0.0.11": {Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456", UID: "0d2c1d4e-9e2b-4b1d-8f2a-2a5b7c9d1e3f"},
+	})
+
+	var tests = []struct {
+		uid  string
+		want modules.Address
+	}{
+		{"0d2c1d4e-9e2b-4b1d-8f2a-2a5b7c9d1e3f", modules.Address{Addr: "127.0.0.1", Port: 8080, Name: "pod.backend-1", Namespace: "shop", WorkloadId: "456"}},
+		{"unknown", modules.Address{Addr: "127.0.0.1", Port: 8080}},
+		{"", modules.Address{Addr: "127.0.0.1", Port: 8080}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uid, func(t *testing.T) {
+			address := modules.Address{Addr: "127.0.0.1", Port: 8080}
+
+			EnrichAddressByPodUID(&address, test.uid)
+
+			assert.EqualValues(t, test.want, address)
+		})
+	}
+}
+
+func TestParseServiceDomains(t *testing.T) {
+
+	assert.EqualValues(t, []string{"svc.cluster.local"}, parseServiceDomains(""))
+	assert.EqualValues(t, []string{"svc.cluster.local"}, parseServiceDomains(" , "))
+	assert.EqualValues(t, []string{"svc.cluster.local", "example.com"}, parseServiceDomains("svc.cluster.local, .Example.com."))
+}
+
+func TestIsExternalIP(t *testing.T) {
+
+	defer func(cidrs []*net.IPNet) { clusterCIDRs = cidrs }(clusterCIDRs)
+	clusterCIDRs = parseCIDRs("100.64.0.0/10, invalid,")
+
+	var tests = []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"10.0.0.1", false},
+		{"192.168.1.1", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.5", false},
+		{"not-an-ip", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			assert.EqualValues(t, test.want, IsExternalIP(test.ip))
+		})
+	}
+}
+
 func TestSliceContains(t *testing.T) {
 
 	slice := []string{"A", "B", "C"}
@@ -41,3 +164,59 @@ func TestSliceContains(t *testing.T) {
 	assert.EqualValues(t, false, SliceContains(slice, "D"))
 
 }
+
+func TestIsTrustedProxy(t *testing.T) {
+
+	defer func(cidrs []*net.IPNet) { trustedProxyCIDRs = cidrs }(trustedProxyCIDRs)
+	trustedProxyCIDRs = parseCIDRs("10.0.0.0/8,192.168.1.10/32")
+
+	assert.EqualValues(t, true, TrustsProxies())
+	assert.EqualValues(t, true, IsTrustedProxy("10.1.2.3"))
+	assert.EqualValues(t, true, IsTrustedProxy("192.168.1.10"))
+	assert.EqualValues(t, false, IsTrustedProxy("192.168.1.11"))
+	assert.EqualValues(t, false, IsTrustedProxy("not-an-ip"))
+
+	trustedProxyCIDRs = parseCIDRs("")
+
+	assert.EqualValues(t, false, TrustsProxies())
+	assert.EqualValues(t, false, IsTrustedProxy("10.1.2.3"))
+}
+
+func TestProxyOrigin(t *testing.T) {
+
+	defer func(size int) { proxyOriginsSize = size }(proxyOriginsSize)
+	proxyOriginsSize = 2
+	proxyOrigins = make(map[string]modules.Address)
+
+	proxy := modules.Address{Addr: "10.0.0.5", Port: 41000}
+	backend := modules.Address{Addr: "10.0.0.11", Port: 8080}
+	origin := modules.Address{Addr: "203.0.113.7", Port: 56324}
+
+	SetProxyOrigin(proxy, backend, origin)
+
+	got, ok := ProxyOrigin(proxy, backend)
+	assert.EqualValues(t, true, ok)
+	assert.EqualValues(t, origin, got)
+
+	_, ok = ProxyOrigin(modules.Address{Addr: "10.0.0.5", Port: 41001}, backend)
+	assert.EqualValues(t, false, ok)
+
+	RemoveProxyOrigin(proxy, backend)
+	_, ok = ProxyOrigin(proxy, backend)
+	assert.EqualValues(t, false, ok)
+
+	// the cache is started over when it is full
+	SetProxyOrigin(proxy, backend, origin)
+	SetProxyOrigin(modules.Address{Addr: "10.0.0.5", Port: 41001}, backend, origin)
+	SetProxyOrigin(modules.Address{Addr: "10.0.0.5", Port: 41002}, backend, origin)
+	_, ok = ProxyOrigin(proxy, backend)
+	assert.EqualValues(t, false, ok)
+	_, ok = ProxyOrigin(modules.Address{Addr: "10.0.0.5", Port: 41002}, backend)
+	assert.EqualValues(t, true, ok)
+}
+
+func TestCacheSize(t *testing.T) {
+	assert.EqualValues(t, 10000, cacheSize(""))
+	assert.EqualValues(t, 10000, cacheSize("0"))
+	assert.EqualValues(t, 50, cacheSize("50"))
+}
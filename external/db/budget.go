@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// set to "true" by k8spacket instances when the response was truncated by the execution budget
+const PartialResultHeader = "X-K8spacket-Partial-Result"
+
+// returned by match functions to stop the scan, records matched so far are kept
+var ErrBudgetExceeded = errors.New("query budget exceeded")
+
+// execution budget shared by all queries of a single API request,
+// limits records scanned (K8S_PACKET_DB_QUERY_MAX_ROWS) and time spent (K8S_PACKET_DB_QUERY_MAX_DURATION)
+type Budget struct {
+	maxRows  int
+	deadline time.Time
+	scanned  int
+	exceeded bool
+	mutex    sync.Mutex
+}
+
+type budgetKey struct{}
+
+func NewBudget() *Budget {
+	maxRows, _ := strconv.Atoi(os.Getenv("K8S_PACKET_DB_QUERY_MAX_ROWS"))
+	maxDuration, _ := time.ParseDuration(os.Getenv("K8S_PACKET_DB_QUERY_MAX_DURATION"))
+
+	budget := &Budget{maxRows: maxRows}
+	if maxDuration > 0 {
+		budget.deadline = time.Now().Add(maxDuration)
+	}
+	return budget
+}
+
+func WithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+func BudgetFrom(ctx context.Context) *Budget {
+	budget, _ := ctx.Value(budgetKey{}).(*Budget)
+	return budget
+}
+
+// results are partial, also when reported by other k8spacket instances
+func (budget *Budget) Exceed() {
+	if budget == nil {
+		return
+	}
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	budget.exceeded = true
+}
+
+func (budget *Budget) Exceeded() bool {
+	if budget == nil {
+		return false
+	}
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	return budget.exceeded
+}
+
+// time left for the request, requests to other k8spacket instances shouldn't outlive it either
+func (budget *Budget) Deadline() (time.Time, bool) {
+	if budget == nil || budget.deadline.IsZero() {
+		return time.Time{}, false
+	}
+	return budget.deadline, true
+}
+
+func (budget *Budget) scan() error {
+	if budget == nil {
+		return nil
+	}
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+
+	budget.scanned++
+	if (budget.maxRows > 0 && budget.scanned > budget.maxRows) || (!budget.deadline.IsZero() && time.Now().After(budget.deadline)) {
+		budget.exceeded = true
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+func (budget *Budget) SetHeader(header http.Header) {
+	if budget.Exceeded() {
+		header.Set(PartialResultHeader, "true")
+	}
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	firstseen_model "github.com/k8spacket/k8spacket/modules/firstseen/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBudget(t *testing.T) {
+
+	defer os.Unsetenv("K8S_PACKET_DB_QUERY_MAX_ROWS")
+	defer os.Unsetenv("K8S_PACKET_DB_QUERY_MAX_DURATION")
+
+	budget := NewBudget()
+
+	assert.EqualValues(t, 0, budget.maxRows)
+	assert.True(t, budget.deadline.IsZero())
+
+	os.Setenv("K8S_PACKET_DB_QUERY_MAX_ROWS", "100")
+	os.Setenv("K8S_PACKET_DB_QUERY_MAX_DURATION", "-1s")
+
+	budget = NewBudget()
+
+	assert.EqualValues(t, 100, budget.maxRows)
+	assert.True(t, budget.deadline.IsZero())
+}
+
+func TestScan(t *testing.T) {
+
+	budget := &Budget{maxRows: 2}
+
+	assert.NoError(t, budget.scan())
+	assert.NoError(t, budget.scan())
+	assert.False(t, budget.Exceeded())
+	assert.ErrorIs(t, budget.scan(), ErrBudgetExceeded)
+	assert.True(t, budget.Exceeded())
+
+	os.Setenv("K8S_PACKET_DB_QUERY_MAX_DURATION", "1ns")
+	defer os.Unsetenv("K8S_PACKET_DB_QUERY_MAX_DURATION")
+
+	budget = NewBudget()
+
+	assert.ErrorIs(t, budget.scan(), ErrBudgetExceeded)
+
+	var noBudget *Budget
+
+	assert.NoError(t, noBudget.scan())
+	assert.False(t, noBudget.Exceeded())
+}
+
+func TestDeadline(t *testing.T) {
+
+	_, ok := (&Budget{}).Deadline()
+	assert.False(t, ok)
+
+	var noBudget *Budget
+	_, ok = noBudget.Deadline()
+	assert.False(t, ok)
+
+	deadline := time.Now().Add(time.Second)
+	result, ok := (&Budget{deadline: deadline}).Deadline()
+	assert.True(t, ok)
+	assert.EqualValues(t, deadline, result)
+}
+
+func TestSetHeader(t *testing.T) {
+
+	header := http.Header{}
+	budget := &Budget{}
+
+	budget.SetHeader(header)
+	assert.Empty(t, header.Get(PartialResultHeader))
+
+	budget.Exceed()
+	budget.SetHeader(header)
+	assert.EqualValues(t, "true", header.Get(PartialResultHeader))
+}
+
+func TestQuery(t *testing.T) {
+
+	handler, err := New[firstseen_model.Destination](filepath.Join(t.TempDir(), "first_seen"))
+	assert.NoError(t, err)
+	defer handler.Close()
+
+	for i := range 5 {
+		handler.Upsert(strconv.Itoa(i), &firstseen_model.Destination{Id: strconv.Itoa(i), Namespace: "ns"})
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var tests = []struct {
+		scenario string
+		ctx      context.Context
+		budget   *Budget
+		results  int
+		exceeded bool
+	}{
+		{"unlimited", context.Background(), nil, 5, false},
+		{"within budget", context.Background(), &Budget{maxRows: 5}, 5, false},
+		{"rows exceeded", context.Background(), &Budget{maxRows: 3}, 3, true},
+		{"cancelled", cancelled, &Budget{}, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			ctx := test.ctx
+			if test.budget != nil {
+				ctx = WithBudget(ctx, test.budget)
+			}
+
+			query := handler.QueryMatchFunc(ctx, "Namespace", func(record *firstseen_model.Destination) (bool, error) {
+				return true, nil
+			})
+			result, err := handler.Query(&query)
+
+			assert.NoError(t, err)
+			assert.Len(t, result, test.results)
+			assert.EqualValues(t, test.exceeded, test.budget.Exceeded())
+		})
+	}
+}
@@ -1,19 +1,21 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	firstseen_model "github.com/k8spacket/k8spacket/modules/firstseen/model"
 	tcp_model "github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	tls_model "github.com/k8spacket/k8spacket/modules/tls-parser/model"
 	"github.com/timshannon/bolthold"
 	"go.etcd.io/bbolt"
-	"hash/fnv"
 )
 
-type BoltDbHandler[T tls_model.TLSDetails | tls_model.TLSConnection | tcp_model.ConnectionItem] struct {
+type BoltDbHandler[T tls_model.TLSDetails | tls_model.TLSConnection | tls_model.TLSPostureSnapshot | tcp_model.ConnectionItem | firstseen_model.Destination] struct {
 	store *bolthold.Store
 }
 
-func New[T tls_model.TLSDetails | tls_model.TLSConnection | tcp_model.ConnectionItem](dbname string) (IDBHandler[T], error) {
+func New[T tls_model.TLSDetails | tls_model.TLSConnection | tls_model.TLSPostureSnapshot | tcp_model.ConnectionItem | firstseen_model.Destination](dbname string) (IDBHandler[T], error) {
 	database, err := bolthold.Open(fmt.Sprintf("%s.db", dbname), 0600, nil)
 	if err != nil {
 		return nil, err
@@ -37,19 +39,31 @@ func (k *BoltDbHandler[T]) Read(key string) (T, error) {
 	})
 }
 
+// records matched before the query was cancelled or its budget exceeded are returned as a partial result
 func (k *BoltDbHandler[T]) Query(query *bolthold.Query) ([]T, error) {
 	var value []T
-	return value, k.store.Bolt().View(func(tx *bbolt.Tx) error {
-		err := k.store.TxFind(tx, &value, query)
-		if err != nil {
-			return err
-		}
-		return nil
+	err := k.store.Bolt().View(func(tx *bbolt.Tx) error {
+		return k.store.TxForEach(tx, query, func(record *T) error {
+			value = append(value, *record)
+			return nil
+		})
 	})
+	if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return value, nil
+	}
+	return value, err
 }
 
-func (k *BoltDbHandler[T]) QueryMatchFunc(field string, matchFunc func(*T) (bool, error)) bolthold.Query {
+// every scanned record is checked against the cancellation and the budget of the context first
+func (k *BoltDbHandler[T]) QueryMatchFunc(ctx context.Context, field string, matchFunc func(*T) (bool, error)) bolthold.Query {
+	var budget = BudgetFrom(ctx)
 	return *bolthold.Where(field).MatchFunc(func(ra *bolthold.RecordAccess) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if err := budget.scan(); err != nil {
+			return false, err
+		}
 		record := ra.Record().(*T)
 		return matchFunc(record)
 	})
@@ -62,8 +76,10 @@ func (k *BoltDbHandler[T]) Upsert(key string, value *T) error {
 		})
 }
 
-func HashId(s string) uint32 {
-	h := fnv.New32a()
-	h.Write([]byte(s))
-	return h.Sum32()
+func (k *BoltDbHandler[T]) Delete(key string) error {
+	var value T
+	return k.store.Bolt().Update(
+		func(tx *bbolt.Tx) error {
+			return k.store.TxDelete(tx, key, value)
+		})
 }
@@ -1,15 +1,19 @@
 package db
 
 import (
+	"context"
+
+	firstseen_model "github.com/k8spacket/k8spacket/modules/firstseen/model"
 	tcp_model "github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	tls_model "github.com/k8spacket/k8spacket/modules/tls-parser/model"
 	"github.com/timshannon/bolthold"
 )
 
-type IDBHandler[T tls_model.TLSDetails | tls_model.TLSConnection | tcp_model.ConnectionItem] interface {
+type IDBHandler[T tls_model.TLSDetails | tls_model.TLSConnection | tls_model.TLSPostureSnapshot | tcp_model.ConnectionItem | firstseen_model.Destination] interface {
 	Query(query *bolthold.Query) ([]T, error)
-	QueryMatchFunc(field string, matchFunc func(*T) (bool, error)) bolthold.Query
+	QueryMatchFunc(ctx context.Context, field string, matchFunc func(*T) (bool, error)) bolthold.Query
 	Read(key string) (T, error)
 	Upsert(key string, value *T) error
+	Delete(key string) error
 	Close() error
 }
@@ -0,0 +1,101 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/k8spacket/k8spacket/external/db"
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/pressure"
+)
+
+// Get requests url (formatted with the instance IP) from all k8spacket instances and merges their responses into out,
+// instances which cannot be reached are skipped, partial or rate limited responses mark the request budget exceeded,
+// instances are queried concurrently, so a slow instance does not use up the time left for the others,
+// requests are cancelled at the deadline of the budget (K8S_PACKET_DB_QUERY_MAX_DURATION),
+// no instance is queried while the node is under pressure, the empty result is marked as partial
+func Get[T any](ctx context.Context, httpClient httpclient.IHttpClient, k8sClient k8sclient.IK8SClient, url string, out T, resultFunc func(d T, s T) T) T {
+	if pressure.Throttled() {
+		slog.Warn("[api] Node under pressure, instances are not queried")
+		db.BudgetFrom(ctx).Exceed()
+		return out
+	}
+
+	k8spacketIps, err := k8sClient.GetPodIPsBySelectors(os.Getenv("K8S_PACKET_API_FIELD_SELECTOR"), os.Getenv("K8S_PACKET_API_LABEL_SELECTOR"))
+	if err != nil {
+		slog.Error("[api] Cannot get k8spacket instances", "Error", err)
+	}
+
+	if deadline, ok := db.BudgetFrom(ctx).Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	responses := make([]T, len(k8spacketIps))
+	received := make([]bool, len(k8spacketIps))
+	var wg sync.WaitGroup
+	for i, ip := range k8spacketIps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// not formatted by fmt, escaped query params like %5E would be taken as verbs
+			responses[i], received[i] = get[T](ctx, httpClient, strings.Replace(url, "%s", ip, 1))
+		}()
+	}
+	wg.Wait()
+
+	// merged in the order of instances, resultFunc is never called concurrently
+	for i, in := range responses {
+		if received[i] {
+			out = resultFunc(out, in)
+		}
+	}
+	return out
+}
+
+func get[T any](ctx context.Context, httpClient httpclient.IHttpClient, url string) (T, bool) {
+	// fresh value for every response, decoding into a reused one could carry over map entries
+	var in T
+	// abandoned requests are cancelled on other k8spacket instances as well
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		slog.Error("[api] Cannot get stats", "Error", err)
+		// instances not asked or not answered in time are missing in the result
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			db.BudgetFrom(ctx).Exceed()
+		}
+		return in, false
+	}
+	defer resp.Body.Close()
+
+	// data of an instance which rejected the request by its rate limit is missing as well
+	if resp.Header.Get(db.PartialResultHeader) == "true" || resp.StatusCode == http.StatusTooManyRequests {
+		db.BudgetFrom(ctx).Exceed()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return in, false
+	}
+
+	responseData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("[api] Cannot read stats response", "Error", err)
+		return in, false
+	}
+
+	err = json.Unmarshal(responseData, &in)
+	if err != nil {
+		slog.Error("[api] Cannot parse stats response", "Error", err)
+		return in, false
+	}
+	return in, true
+}
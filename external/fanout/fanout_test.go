@@ -0,0 +1,187 @@
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockK8SClient struct {
+	ips []string
+	err error
+}
+
+func (mock *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
+	return mock.ips, mock.err
+}
+
+type mockHttpClient struct {
+	responses map[string]*http.Response
+	closed    int
+	request   *http.Request
+	delay     time.Duration
+	mutex     sync.Mutex
+}
+
+func (mock *mockHttpClient) Do(req *http.Request) (*http.Response, error) {
+	select {
+	case <-time.After(mock.delay):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.request = req
+	if deadline, ok := req.Context().Deadline(); ok && !deadline.After(time.Now()) {
+		return nil, req.Context().Err()
+	}
+	resp, ok := mock.responses[req.URL.Hostname()]
+	if !ok {
+		return nil, errors.New("refused")
+	}
+	resp.Body = &closeCounter{resp.Body, mock}
+	return resp, nil
+}
+
+type closeCounter struct {
+	io.Reader
+	mock *mockHttpClient
+}
+
+func (body *closeCounter) Close() error {
+	body.mock.mutex.Lock()
+	defer body.mock.mutex.Unlock()
+
+	body.mock.closed++
+	return nil
+}
+
+func response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString(body))}
+}
+
+func TestGet(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	partial := response(http.StatusOK, `{"b":2}`)
+	partial.Header.Set(db.PartialResultHeader, "true")
+
+	httpClient := &mockHttpClient{responses: map[string]*http.Response{
+		"10.0.0.1": response(http.StatusOK, `{"a":1}`),
+		"10.0.0.2": partial,
+		"10.0.0.3": response(http.StatusInternalServerError, `{"c":3}`),
+		"10.0.0.4": response(http.StatusOK, "parse error"),
+	}}
+	k8sClient := &mockK8SClient{ips: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}}
+
+	budget := db.NewBudget()
+	result := Get(db.WithBudget(context.Background(), budget), httpClient, k8sClient, "http://%s:6676/?namespace=%5Ens%24", map[string]int{},
+		func(d map[string]int, s map[string]int) map[string]int {
+			for key, value := range s {
+				d[key] += value
+			}
+			return d
+		})
+
+	assert.EqualValues(t, map[string]int{"a": 1, "b": 2}, result)
+	assert.True(t, budget.Exceeded())
+	assert.EqualValues(t, 4, httpClient.closed)
+	assert.EqualValues(t, "^ns$", httpClient.request.URL.Query().Get("namespace"))
+	assert.Contains(t, str.String(), "[api] Cannot get stats")
+	assert.Contains(t, str.String(), "[api] Cannot parse stats response")
+}
+
+func TestGetRateLimited(t *testing.T) {
+
+	httpClient := &mockHttpClient{responses: map[string]*http.Response{
+		"10.0.0.1": response(http.StatusOK, `["a"]`),
+		"10.0.0.2": response(http.StatusTooManyRequests, "Too Many Requests"),
+	}}
+
+	budget := db.NewBudget()
+	result := Get(db.WithBudget(context.Background(), budget), httpClient, &mockK8SClient{ips: []string{"10.0.0.1", "10.0.0.2"}}, "http://%s:6676/", []string{}, func(d []string, s []string) []string {
+		return append(d, s...)
+	})
+
+	assert.EqualValues(t, []string{"a"}, result)
+	assert.True(t, budget.Exceeded())
+}
+
+func TestGetNoInstances(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	result := Get(context.Background(), &mockHttpClient{}, &mockK8SClient{err: errors.New("forbidden")}, "http://%s:6676/", []string{}, func(d []string, s []string) []string {
+		return append(d, s...)
+	})
+
+	assert.EqualValues(t, []string{}, result)
+	assert.Contains(t, str.String(), "[api] Cannot get k8spacket instances")
+}
+
+func TestGetDeadline(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	httpClient := &mockHttpClient{responses: map[string]*http.Response{
+		"10.0.0.1": response(http.StatusOK, `["a"]`),
+	}}
+	k8sClient := &mockK8SClient{ips: []string{"10.0.0.1"}}
+	merge := func(d []string, s []string) []string {
+		return append(d, s...)
+	}
+
+	t.Setenv("K8S_PACKET_DB_QUERY_MAX_DURATION", "1m")
+	budget := db.NewBudget()
+
+	result := Get(db.WithBudget(context.Background(), budget), httpClient, k8sClient, "http://%s:6676/", []string{}, merge)
+
+	deadline, _ := httpClient.request.Context().Deadline()
+	expected, _ := budget.Deadline()
+	assert.EqualValues(t, expected, deadline)
+	assert.EqualValues(t, []string{"a"}, result)
+	assert.False(t, budget.Exceeded())
+
+	t.Setenv("K8S_PACKET_DB_QUERY_MAX_DURATION", "1ns")
+	budget = db.NewBudget()
+	time.Sleep(time.Millisecond)
+
+	result = Get(db.WithBudget(context.Background(), budget), httpClient, k8sClient, "http://%s:6676/", []string{}, merge)
+
+	assert.EqualValues(t, []string{}, result)
+	assert.True(t, budget.Exceeded())
+}
+
+func TestGetConcurrently(t *testing.T) {
+
+	httpClient := &mockHttpClient{delay: 100 * time.Millisecond, responses: map[string]*http.Response{
+		"10.0.0.1": response(http.StatusOK, `["a"]`),
+		"10.0.0.2": response(http.StatusOK, `["b"]`),
+		"10.0.0.3": response(http.StatusOK, `["c"]`),
+	}}
+
+	// every instance is slow, but all of them answer within the deadline
+	t.Setenv("K8S_PACKET_DB_QUERY_MAX_DURATION", "250ms")
+	budget := db.NewBudget()
+
+	result := Get(db.WithBudget(context.Background(), budget), httpClient, &mockK8SClient{ips: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}, "http://%s:6676/", []string{}, func(d []string, s []string) []string {
+		return append(d, s...)
+	})
+
+	assert.EqualValues(t, []string{"a", "b", "c"}, result)
+	assert.False(t, budget.Exceeded())
+}
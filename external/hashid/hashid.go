@@ -0,0 +1,9 @@
+package hashid
+
+import "hash/fnv"
+
+func HashId(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
@@ -1,11 +1,19 @@
 package httpclient
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type HttpClient struct {
 	IHttpClient
+	// zero means no timeout, as for http.DefaultClient
+	Timeout time.Duration
 }
 
 func (httpClient *HttpClient) Do(req *http.Request) (*http.Response, error) {
+	if httpClient.Timeout > 0 {
+		return (&http.Client{Timeout: httpClient.Timeout}).Do(req)
+	}
 	return http.DefaultClient.Do(req)
 }
@@ -9,11 +9,17 @@ import (
 	"k8s.io/client-go/rest"
 	"os"
 	"strconv"
+	"strings"
+
+	"github.com/k8spacket/k8spacket/external/hashid"
 )
 
 type IPResourceInfo struct {
-	Name      string
-	Namespace string
+	Name       string
+	Namespace  string
+	WorkloadId string
+	// pods only, e.g. to attribute loopback connections by the pod cgroup
+	UID string
 }
 
 type K8SClient struct {
@@ -24,11 +30,11 @@ var _, clientset = configClusterClient()
 
 var disabledK8sResource, _ = strconv.ParseBool(os.Getenv("K8S_PACKET_K8S_RESOURCES_DISABLED"))
 
-func FetchK8SInfo() map[string]IPResourceInfo {
+func FetchK8SInfo() (map[string]IPResourceInfo, error) {
 
 	if disabledK8sResource {
 		fmt.Println("Getting k8s resources is disabled")
-		return map[string]IPResourceInfo{}
+		return map[string]IPResourceInfo{}, nil
 	}
 
 	fmt.Println("Getting k8s resources")
@@ -37,8 +43,7 @@ func FetchK8SInfo() map[string]IPResourceInfo {
 
 	pods, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		fmt.Println(err.Error())
-		panic(err.Error())
+		return nil, err
 	}
 	fmt.Printf("Found %d pods\n", len(pods.Items))
 	for i := range pods.Items {
@@ -46,13 +51,15 @@ func FetchK8SInfo() map[string]IPResourceInfo {
 		pod := pods.Items[i]
 		ipResourceInfo.Name = "pod." + pod.Name
 		ipResourceInfo.Namespace = pod.Namespace
+		kind, name := podWorkload(pod)
+		ipResourceInfo.WorkloadId = workloadId(pod.Namespace, kind, name)
+		ipResourceInfo.UID = string(pod.UID)
 		m[pod.Status.PodIP] = *ipResourceInfo
 	}
 
 	services, err := clientset.CoreV1().Services("").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		fmt.Println(err.Error())
-		panic(err.Error())
+		return nil, err
 	}
 	fmt.Printf("Found %d services\n", len(services.Items))
 	for i := range services.Items {
@@ -60,12 +67,12 @@ func FetchK8SInfo() map[string]IPResourceInfo {
 		service := services.Items[i]
 		ipResourceInfo.Name = "svc." + service.Name
 		ipResourceInfo.Namespace = service.Namespace
+		ipResourceInfo.WorkloadId = workloadId(service.Namespace, "Service", service.Name)
 		m[service.Spec.ClusterIP] = *ipResourceInfo
 	}
 	nodes, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		fmt.Println(err.Error())
-		panic(err.Error())
+		return nil, err
 	}
 	fmt.Printf("Found %d nodes\n", len(nodes.Items))
 	for i := range nodes.Items {
@@ -73,6 +80,7 @@ func FetchK8SInfo() map[string]IPResourceInfo {
 		node := nodes.Items[i]
 		ipResourceInfo.Name = "node." + node.Name
 		ipResourceInfo.Namespace = "N/A"
+		ipResourceInfo.WorkloadId = workloadId("", "Node", node.Name)
 		for _, address := range node.Status.Addresses {
 			if address.Type == v1.NodeInternalIP {
 				m[address.Address] = *ipResourceInfo
@@ -80,28 +88,46 @@ func FetchK8SInfo() map[string]IPResourceInfo {
 			}
 		}
 	}
-	return m
+	return m, nil
+}
+
+// pods are identified by the workload controlling them, so the identifier survives rescheduling with a new IP
+func podWorkload(pod v1.Pod) (string, string) {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		// pods of Deployment are owned by ReplicaSet named {{deployment}}-{{pod-template-hash}}
+		if hash, ok := pod.Labels["pod-template-hash"]; ok && owner.Kind == "ReplicaSet" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+		return owner.Kind, owner.Name
+	}
+	return "Pod", pod.Name
 }
 
-func (k8sClient *K8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) []string {
+func workloadId(namespace string, kind string, name string) string {
+	return strconv.Itoa(int(hashid.HashId(fmt.Sprintf("%s/%s/%s", namespace, kind, name))))
+}
+
+func (k8sClient *K8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
 
 	if disabledK8sResource {
-		return []string{"127.0.0.1"}
+		return []string{"127.0.0.1"}, nil
 	}
 
 	list := make([]string, 0)
 
 	pods, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{FieldSelector: fieldSelector, LabelSelector: labelSelector})
 	if err != nil {
-		fmt.Println(err.Error())
-		panic(err.Error())
+		return nil, err
 	}
 
 	for _, pod := range pods.Items {
 		list = append(list, pod.Status.PodIP)
 	}
 
-	return list
+	return list, nil
 }
 
 func configClusterClient() (error, *kubernetes.Clientset) {
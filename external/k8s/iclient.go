@@ -1,5 +1,5 @@
 package k8sclient
 
 type IK8SClient interface {
-	GetPodIPsBySelectors(fieldSelector string, labelSelector string) []string
+	GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error)
 }
@@ -0,0 +1,10 @@
+package netlinkclient
+
+import "github.com/vishvananda/netlink"
+
+type INetlink interface {
+	LinkByName(name string) (netlink.Link, error)
+	QdiscDel(qdisc netlink.Qdisc) error
+	QdiscAdd(qdisc netlink.Qdisc) error
+	FilterAdd(filter netlink.Filter) error
+}
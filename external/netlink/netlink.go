@@ -0,0 +1,23 @@
+package netlinkclient
+
+import "github.com/vishvananda/netlink"
+
+type Netlink struct {
+	INetlink
+}
+
+func (client *Netlink) LinkByName(name string) (netlink.Link, error) {
+	return netlink.LinkByName(name)
+}
+
+func (client *Netlink) QdiscDel(qdisc netlink.Qdisc) error {
+	return netlink.QdiscDel(qdisc)
+}
+
+func (client *Netlink) QdiscAdd(qdisc netlink.Qdisc) error {
+	return netlink.QdiscAdd(qdisc)
+}
+
+func (client *Netlink) FilterAdd(filter netlink.Filter) error {
+	return netlink.FilterAdd(filter)
+}
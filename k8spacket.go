@@ -3,19 +3,32 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"syscall"
 
 	"github.com/k8spacket/k8spacket/broker"
+	broker_prometheus "github.com/k8spacket/k8spacket/broker/prometheus"
 	"github.com/k8spacket/k8spacket/ebpf"
 	ebpf_inet "github.com/k8spacket/k8spacket/ebpf/inet"
 	ebpf_tc "github.com/k8spacket/k8spacket/ebpf/tc"
+	netlinkclient "github.com/k8spacket/k8spacket/external/netlink"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/firstseen"
+	"github.com/k8spacket/k8spacket/modules/loopback"
 	"github.com/k8spacket/k8spacket/modules/nodegraph"
+	"github.com/k8spacket/k8spacket/modules/telemetry"
 	tlsparser "github.com/k8spacket/k8spacket/modules/tls-parser"
+	"github.com/k8spacket/k8spacket/pressure"
+	"github.com/k8spacket/k8spacket/ratelimit"
+	"github.com/k8spacket/k8spacket/security"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -23,32 +36,80 @@ import (
 
 func main() {
 
+	printRequiredCaps := flag.Bool("print-required-caps", false, "print the minimal container securityContext and exit")
+	printSeccompProfile := flag.Bool("print-seccomp-profile", false, "print the seccomp profile allowing syscalls of all features and exit")
+	flag.Parse()
+
+	if *printRequiredCaps {
+		security.PrintRequiredCaps(os.Stdout)
+		return
+	}
+
+	if *printSeccompProfile {
+		features := slices.Sorted(maps.Keys(security.Features))
+		if err := security.WriteSeccompProfile(os.Stdout, features...); err != nil {
+			slog.Error("[security] Cannot print seccomp profile", "Error", err)
+		}
+		return
+	}
+
+	verifyCapabilities()
+
 	mux := http.NewServeMux()
 
+	// modules store their data in bolt databases
+	security.UseFeature(security.FeatureDB)
 	nodegraphListener := nodegraph.Init(mux)
 	tlsParserListener := tlsparser.Init(mux)
-	broker := broker.Init(nodegraphListener, tlsParserListener)
+	firstSeenTCPListener, firstSeenTLSListener := firstseen.Init(mux)
+	telemetryTCPListener, telemetryTLSListener := telemetry.Init(mux)
+	loopbackListener := loopback.Init(mux)
+	broker := broker.Init(
+		modules.Listeners[modules.TCPEvent]{nodegraphListener, firstSeenTCPListener, telemetryTCPListener},
+		modules.Listeners[modules.TLSEvent]{tlsParserListener, firstSeenTLSListener, telemetryTLSListener},
+		loopbackListener)
 
 	inetEbpf := &ebpf_inet.InetEbpf{Broker: broker}
-	tcEbpf := &ebpf_tc.TcEbpf{Broker: broker}
+	tcEbpf := &ebpf_tc.TcEbpf{Broker: broker, Netlink: &netlinkclient.Netlink{}, Loader: &ebpf_tc.TcObjectsLoader{}}
 	loader := ebpf.Init(inetEbpf, tcEbpf)
 
+	monitor := pressure.Init(broker, tcEbpf)
+	go monitor.Watch()
+
 	startApp(broker, loader, mux)
 }
 
+// fail fast with actionable message instead of obscure errors of loading eBPF programs
+func verifyCapabilities() {
+	if disabled, _ := strconv.ParseBool(os.Getenv("K8S_PACKET_CAPABILITIES_CHECK_DISABLED")); disabled {
+		return
+	}
+
+	warnings, err := security.VerifyCapabilities()
+	for _, warning := range warnings {
+		slog.Warn("[security] Missing optional capability", "capability", warning)
+	}
+	if err != nil {
+		slog.Error("[security] Cannot start", "Error", err)
+		os.Exit(1)
+	}
+}
+
 func startApp(broker broker.IBroker, loader ebpf.ILoader, mux *http.ServeMux) {
 	go broker.DistributeEvents()
 	loader.Load()
 
 	prometheus.MustRegister(collectors.NewBuildInfoCollector())
+	broker_prometheus.Init()
 	startHttpServer(mux)
 }
 
 func startHttpServer(mux *http.ServeMux) {
 	listenerPort := os.Getenv("K8S_PACKET_TCP_LISTENER_PORT")
 	slog.Info("[api] Serving requests", "Port", listenerPort)
+	security.UseFeature(security.FeatureNetwork)
 
-	srv := &http.Server{Addr: fmt.Sprintf(":%s", listenerPort), Handler: mux}
+	srv := &http.Server{Addr: fmt.Sprintf(":%s", listenerPort), Handler: ratelimit.Init().Handler(mux)}
 	go func() {
 		mux.Handle("/metrics", promhttp.Handler())
 		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
@@ -0,0 +1,55 @@
+package firstseen
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/k8spacket/k8spacket/external/db"
+)
+
+type Controller struct {
+	service IService
+}
+
+// destinations first seen by this k8spacket instance
+func (controller *Controller) DestinationsHandler(w http.ResponseWriter, r *http.Request) {
+	patternNs, err := regexp.Compile(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	budget := db.NewBudget()
+	destinations := controller.service.getDestinations(db.WithBudget(r.Context(), budget), patternNs)
+	budget.SetHeader(w.Header())
+
+	prepareResponse(w, destinations)
+}
+
+// destinations first seen by any k8spacket instance in the cluster
+func (controller *Controller) ClusterDestinationsHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := regexp.Compile(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	budget := db.NewBudget()
+	destinations := controller.service.buildDestinationsResponse(db.WithBudget(r.Context(), budget), fmt.Sprintf("http://%%s:%s/firstseen/destinations?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), r.URL.Query().Encode()))
+	budget.SetHeader(w.Header())
+
+	prepareResponse(w, destinations)
+}
+
+func prepareResponse(w http.ResponseWriter, destinations any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(destinations)
+	if err != nil {
+		slog.Error("[api] Cannot prepare first-seen destinations response", "Error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
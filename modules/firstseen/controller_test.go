@@ -0,0 +1,70 @@
+package firstseen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestinationsHandler(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		query    string
+		status   int
+		body     string
+	}{
+		{"ok", "namespace=ns", http.StatusOK, "\"destination\":\"k8spacket.io\""},
+		{"wrong regexp", "namespace=(", http.StatusBadRequest, "error parsing regexp"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			controller := &Controller{&mockService{}}
+
+			req, _ := http.NewRequest(http.MethodGet, "/firstseen/destinations?"+test.query, nil)
+			rr := httptest.NewRecorder()
+
+			controller.DestinationsHandler(rr, req)
+
+			assert.EqualValues(t, test.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), test.body)
+		})
+	}
+}
+
+func TestClusterDestinationsHandler(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		query    string
+		status   int
+		body     string
+		url      []string
+	}{
+		{"ok", "namespace=ns", http.StatusOK, "\"destination\":\"k8spacket.io\"", []string{"http://%s:6676/firstseen/destinations?namespace=ns"}},
+		{"wrong regexp", "namespace=(", http.StatusBadRequest, "error parsing regexp", nil},
+	}
+
+	t.Setenv("K8S_PACKET_TCP_LISTENER_PORT", "6676")
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			service := &mockService{}
+			controller := &Controller{service}
+
+			req, _ := http.NewRequest(http.MethodGet, "/firstseen/api/destinations?"+test.query, nil)
+			rr := httptest.NewRecorder()
+
+			controller.ClusterDestinationsHandler(rr, req)
+
+			assert.EqualValues(t, test.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), test.body)
+			assert.EqualValues(t, test.url, service.registered)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package firstseen
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/db"
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+	"github.com/k8spacket/k8spacket/modules/firstseen/prometheus"
+	"github.com/k8spacket/k8spacket/modules/firstseen/repository"
+)
+
+func Init(mux *http.ServeMux) (modules.IListener[modules.TCPEvent], modules.IListener[modules.TLSEvent]) {
+
+	prometheus.Init()
+
+	handler, _ := db.New[model.Destination]("first_seen")
+	repo := &repository.Repository{DbHandler: handler}
+	webhookTimeout, err := time.ParseDuration(os.Getenv("K8S_PACKET_FIRST_SEEN_WEBHOOK_TIMEOUT"))
+	if err != nil || webhookTimeout <= 0 {
+		webhookTimeout = 5 * time.Second
+	}
+	service := &Service{repo: repo, httpClient: &httpclient.HttpClient{}, webhookClient: &httpclient.HttpClient{Timeout: webhookTimeout}, k8sClient: &k8sclient.K8SClient{},
+		seen: make(map[string]bool), notifications: make(chan model.Destination, notificationsQueueSize)}
+	controller := &Controller{service}
+
+	mux.HandleFunc("/firstseen/destinations", controller.DestinationsHandler)
+	mux.HandleFunc("/firstseen/api/destinations", controller.ClusterDestinationsHandler)
+
+	go service.sendNotifications()
+
+	return &TCPListener{service}, &TLSListener{service}
+
+}
@@ -0,0 +1,17 @@
+package firstseen
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+)
+
+type IService interface {
+	register(client modules.Address, kind string, destination string)
+
+	getDestinations(ctx context.Context, patternNs *regexp.Regexp) []model.Destination
+
+	buildDestinationsResponse(ctx context.Context, url string) []model.Destination
+}
@@ -0,0 +1,37 @@
+package firstseen
+
+import (
+	"net"
+
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/modules"
+)
+
+const (
+	kindIP  = "ip"
+	kindSNI = "sni"
+)
+
+type TCPListener struct {
+	service IService
+}
+
+type TLSListener struct {
+	service IService
+}
+
+// addresses which are not k8s resources keep the IP as the workload identifier,
+// private and cluster ranges are skipped, also pods and nodes not known (anymore) to the k8s info
+func (listener *TCPListener) Listen(event modules.TCPEvent) {
+	if event.Client.Namespace == "" || event.Server.Namespace != "" || net.ParseIP(event.Server.WorkloadId) == nil || !ebpf_tools.IsExternalIP(event.Server.Addr) {
+		return
+	}
+	listener.service.register(event.Client, kindIP, event.Server.Addr)
+}
+
+func (listener *TLSListener) Listen(event modules.TLSEvent) {
+	if event.Client.Namespace == "" || event.Server.Namespace != "" || event.ServerName == "" {
+		return
+	}
+	listener.service.register(event.Client, kindSNI, event.ServerName)
+}
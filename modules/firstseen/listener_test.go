@@ -0,0 +1,81 @@
+package firstseen
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockService struct {
+	IService
+	registered []string
+}
+
+func (mock *mockService) register(client modules.Address, kind string, destination string) {
+	mock.registered = append(mock.registered, client.Namespace+"/"+kind+"/"+destination)
+}
+
+func (mock *mockService) getDestinations(ctx context.Context, patternNs *regexp.Regexp) []model.Destination {
+	return []model.Destination{{Namespace: "ns", Kind: kindSNI, Destination: "k8spacket.io"}}
+}
+
+func (mock *mockService) buildDestinationsResponse(ctx context.Context, url string) []model.Destination {
+	mock.registered = append(mock.registered, url)
+	return []model.Destination{{Namespace: "ns", Kind: kindSNI, Destination: "k8spacket.io"}}
+}
+
+func TestTCPListen(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		event    modules.TCPEvent
+		want     []string
+	}{
+		{"external", modules.TCPEvent{Client: modules.Address{Namespace: "ns"}, Server: modules.Address{Addr: "1.1.1.1", WorkloadId: "1.1.1.1"}}, []string{"ns/ip/1.1.1.1"}},
+		{"in cluster", modules.TCPEvent{Client: modules.Address{Namespace: "ns"}, Server: modules.Address{Addr: "10.0.0.1", Namespace: "other", WorkloadId: "uid"}}, nil},
+		{"node", modules.TCPEvent{Client: modules.Address{Namespace: "ns"}, Server: modules.Address{Addr: "10.0.0.1", WorkloadId: "node-1"}}, nil},
+		{"private", modules.TCPEvent{Client: modules.Address{Namespace: "ns"}, Server: modules.Address{Addr: "10.0.0.2", WorkloadId: "10.0.0.2"}}, nil},
+		{"from outside", modules.TCPEvent{Client: modules.Address{Addr: "1.1.1.1"}, Server: modules.Address{Addr: "2.2.2.2", WorkloadId: "2.2.2.2"}}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			service := &mockService{}
+			listener := &TCPListener{service}
+
+			listener.Listen(test.event)
+
+			assert.EqualValues(t, test.want, service.registered)
+		})
+	}
+}
+
+func TestTLSListen(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		event    modules.TLSEvent
+		want     []string
+	}{
+		{"external", modules.TLSEvent{Client: modules.Address{Namespace: "ns"}, ServerName: "k8spacket.io"}, []string{"ns/sni/k8spacket.io"}},
+		{"in cluster", modules.TLSEvent{Client: modules.Address{Namespace: "ns"}, Server: modules.Address{Namespace: "other"}, ServerName: "svc.other"}, nil},
+		{"no SNI", modules.TLSEvent{Client: modules.Address{Namespace: "ns"}}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			service := &mockService{}
+			listener := &TLSListener{service}
+
+			listener.Listen(test.event)
+
+			assert.EqualValues(t, test.want, service.registered)
+		})
+	}
+}
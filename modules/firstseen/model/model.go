@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// external destination (SNI or IP) contacted from the namespace for the first time
+type Destination struct {
+	Id          string    `json:"id"`
+	Namespace   string    `json:"namespace"`
+	Kind        string    `json:"kind"`
+	Destination string    `json:"destination"`
+	Workload    string    `json:"workload"`
+	FirstSeen   time.Time `json:"firstSeen"`
+}
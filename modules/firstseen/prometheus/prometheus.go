@@ -0,0 +1,19 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	K8sPacketFirstSeenDestinationsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8s_packet_first_seen_destinations",
+			Help: "Kubernetes packet external destinations contacted by namespaces for the first time",
+		},
+		[]string{"ns", "kind"},
+	)
+)
+
+func Init() {
+	prometheus.MustRegister(K8sPacketFirstSeenDestinationsMetric)
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+)
+
+type IRepository interface {
+	Read(key string) model.Destination
+	Query(ctx context.Context, patternNs *regexp.Regexp) []model.Destination
+	Set(key string, value *model.Destination)
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+
+	"github.com/k8spacket/k8spacket/external/db"
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+)
+
+type Repository struct {
+	DbHandler db.IDBHandler[model.Destination]
+}
+
+func (repository *Repository) Read(key string) model.Destination {
+	result, err := repository.DbHandler.Read(key)
+	if err != nil {
+		//not seen yet, silent
+		return model.Destination{}
+	}
+	return result
+}
+
+func (repository *Repository) Query(ctx context.Context, patternNs *regexp.Regexp) []model.Destination {
+
+	query := repository.DbHandler.QueryMatchFunc(ctx, "Namespace", func(record *model.Destination) (bool, error) {
+		return patternNs.MatchString(record.Namespace), nil
+	})
+
+	result, err := repository.DbHandler.Query(&query)
+	if err != nil {
+		slog.Error("[db:first_seen:Query]", "Error", err)
+		return []model.Destination{}
+	}
+	return result
+}
+
+func (repository *Repository) Set(key string, value *model.Destination) {
+	err := repository.DbHandler.Upsert(key, value)
+	if err != nil {
+		slog.Error("[db:first_seen:Upsert]", "Error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package firstseen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/fanout"
+	"github.com/k8spacket/k8spacket/external/hashid"
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+	"github.com/k8spacket/k8spacket/modules/firstseen/prometheus"
+	"github.com/k8spacket/k8spacket/modules/firstseen/repository"
+	"github.com/k8spacket/k8spacket/pressure"
+)
+
+// notifications waiting for the webhook receiver, newer ones are dropped when it cannot keep up
+const notificationsQueueSize = 100
+
+// how often waiting notifications check whether the node is still under pressure
+const pressureCheckPeriod = time.Second
+
+type Service struct {
+	repo          repository.IRepository
+	httpClient    httpclient.IHttpClient
+	webhookClient httpclient.IHttpClient
+	k8sClient     k8sclient.IK8SClient
+	seen          map[string]bool
+	notifications chan model.Destination
+	mutex         sync.Mutex
+}
+
+// known pairs kept in memory, K8S_PACKET_FIRST_SEEN_CACHE_SIZE (10000 by default)
+var seenCacheSize = cacheSize(os.Getenv("K8S_PACKET_FIRST_SEEN_CACHE_SIZE"))
+
+func cacheSize(value string) int {
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return 10000
+	}
+	return size
+}
+
+// the registry is kept by every k8spacket instance, so a destination is reported once per node it is seen from,
+// the id is the same on all nodes, so webhook receivers can deduplicate by it
+func (service *Service) register(client modules.Address, kind string, destination string) {
+	var id = strconv.Itoa(int(hashid.HashId(fmt.Sprintf("%s-%s-%s", client.Namespace, kind, destination))))
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	// cache of known pairs to not hit the database on every event,
+	// it only saves reads, so it is started over when full
+	if service.seen[id] {
+		return
+	}
+	if len(service.seen) >= seenCacheSize {
+		service.seen = make(map[string]bool)
+	}
+	service.seen[id] = true
+
+	if (service.repo.Read(id) != model.Destination{}) {
+		return
+	}
+
+	var firstSeen = model.Destination{Id: id, Namespace: client.Namespace, Kind: kind, Destination: destination, Workload: client.Name, FirstSeen: time.Now()}
+	service.repo.Set(id, &firstSeen)
+
+	prometheus.K8sPacketFirstSeenDestinationsMetric.WithLabelValues(firstSeen.Namespace, firstSeen.Kind).Inc()
+	slog.Warn("[first-seen] New external destination", "namespace", firstSeen.Namespace, "kind", firstSeen.Kind, "destination", firstSeen.Destination, "workload", firstSeen.Workload)
+
+	if os.Getenv("K8S_PACKET_FIRST_SEEN_WEBHOOK_URL") == "" {
+		return
+	}
+	// do not block events distribution by a slow webhook receiver
+	select {
+	case service.notifications <- firstSeen:
+	default:
+		slog.Warn("[first-seen] Webhook queue is full, notification dropped", "namespace", firstSeen.Namespace, "kind", firstSeen.Kind, "destination", firstSeen.Destination)
+	}
+}
+
+// single worker, so a slow webhook receiver holds at most one request at a time
+func (service *Service) sendNotifications() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(pressureCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		// notifications wait in the queue while the node is under pressure, receiving from a nil channel blocks
+		var notifications chan model.Destination
+		if !pressure.Throttled() {
+			notifications = service.notifications
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("[first-seen] Receive signal, exiting...")
+			return
+		case <-ticker.C:
+		case firstSeen := <-notifications:
+			service.notify(firstSeen)
+		}
+	}
+}
+
+// POST the destination as JSON to K8S_PACKET_FIRST_SEEN_WEBHOOK_URL
+func (service *Service) notify(firstSeen model.Destination) {
+	var webhookUrl = os.Getenv("K8S_PACKET_FIRST_SEEN_WEBHOOK_URL")
+	if webhookUrl == "" {
+		return
+	}
+
+	body, _ := json.Marshal(firstSeen)
+	req, err := http.NewRequest(http.MethodPost, webhookUrl, bytes.NewBuffer(body))
+	if err != nil {
+		slog.Error("[first-seen] Cannot prepare webhook request", "Error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := service.webhookClient.Do(req)
+	if err != nil {
+		slog.Error("[first-seen] Cannot send webhook", "Error", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		slog.Error("[first-seen] Webhook rejected", "Status", resp.StatusCode)
+	}
+}
+
+func (service *Service) getDestinations(ctx context.Context, patternNs *regexp.Regexp) []model.Destination {
+	return service.repo.Query(ctx, patternNs)
+}
+
+// destinations from all k8spacket instances, a destination seen from many nodes is listed once with its earliest sighting
+func (service *Service) buildDestinationsResponse(ctx context.Context, url string) []model.Destination {
+	resultFunc := func(destination, source []model.Destination) []model.Destination {
+		for _, firstSeen := range source {
+			index := slices.IndexFunc(destination, func(d model.Destination) bool { return d.Id == firstSeen.Id })
+			if index < 0 {
+				destination = append(destination, firstSeen)
+			} else if firstSeen.FirstSeen.Before(destination[index].FirstSeen) {
+				destination[index] = firstSeen
+			}
+		}
+		return destination
+	}
+	destinations := fanout.Get(ctx, service.httpClient, service.k8sClient, url, []model.Destination{}, resultFunc)
+	slices.SortFunc(destinations, func(a, b model.Destination) int { return a.FirstSeen.Compare(b.FirstSeen) })
+	return destinations
+}
@@ -0,0 +1,225 @@
+package firstseen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/firstseen/model"
+	"github.com/k8spacket/k8spacket/modules/firstseen/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRepository struct {
+	repository.IRepository
+	stored    map[string]model.Destination
+	reads     int
+	patternNs *regexp.Regexp
+}
+
+func (mock *mockRepository) Read(key string) model.Destination {
+	mock.reads++
+	return mock.stored[key]
+}
+
+func (mock *mockRepository) Query(ctx context.Context, patternNs *regexp.Regexp) []model.Destination {
+	mock.patternNs = patternNs
+	var result []model.Destination
+	for _, destination := range mock.stored {
+		result = append(result, destination)
+	}
+	return result
+}
+
+func (mock *mockRepository) Set(key string, value *model.Destination) {
+	mock.stored[key] = *value
+}
+
+type mockHttpClient struct {
+	status    int
+	err       error
+	request   *http.Request
+	body      []byte
+	responses map[string][]model.Destination
+	mutex     sync.Mutex
+}
+
+func (mock *mockHttpClient) Do(req *http.Request) (*http.Response, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.request = req
+	if req.Body != nil {
+		mock.body, _ = io.ReadAll(req.Body)
+	}
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	if mock.responses != nil {
+		result, _ := json.Marshal(mock.responses[req.URL.Hostname()])
+		return &http.Response{Body: io.NopCloser(bytes.NewBuffer(result)), StatusCode: http.StatusOK}, nil
+	}
+	return &http.Response{Body: io.NopCloser(bytes.NewBuffer(nil)), StatusCode: mock.status}, nil
+}
+
+type mockK8SClient struct {
+	k8sclient.IK8SClient
+	ips []string
+}
+
+func (mock *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
+	return mock.ips, nil
+}
+
+func TestRegister(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	mockRepository := &mockRepository{stored: make(map[string]model.Destination)}
+	service := &Service{repo: mockRepository, httpClient: &mockHttpClient{}, seen: make(map[string]bool)}
+
+	client := modules.Address{Namespace: "ns", Name: "client"}
+
+	service.register(client, kindSNI, "k8spacket.io")
+	service.register(client, kindSNI, "k8spacket.io")
+	service.register(modules.Address{Namespace: "other", Name: "client"}, kindSNI, "k8spacket.io")
+	service.register(client, kindIP, "1.1.1.1")
+
+	assert.Len(t, mockRepository.stored, 3)
+	assert.EqualValues(t, 3, mockRepository.reads)
+	assert.EqualValues(t, 3, bytes.Count(str.Bytes(), []byte("[first-seen] New external destination")))
+	assert.Contains(t, str.String(), "namespace=ns kind=sni destination=k8spacket.io workload=client")
+
+	// destinations persisted before restart are not reported again
+	str.Reset()
+	service = &Service{repo: mockRepository, httpClient: &mockHttpClient{}, seen: make(map[string]bool)}
+
+	service.register(client, kindSNI, "k8spacket.io")
+
+	assert.Len(t, mockRepository.stored, 3)
+	assert.NotContains(t, str.String(), "[first-seen] New external destination")
+}
+
+func TestNotify(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	defer os.Unsetenv("K8S_PACKET_FIRST_SEEN_WEBHOOK_URL")
+
+	var tests = []struct {
+		scenario string
+		url      string
+		client   *mockHttpClient
+		sent     bool
+		err      string
+	}{
+		{"disabled", "", &mockHttpClient{}, false, ""},
+		{"ok", "http://webhook/hook", &mockHttpClient{status: http.StatusOK}, true, ""},
+		{"rejected", "http://webhook/hook", &mockHttpClient{status: http.StatusForbidden}, true, "[first-seen] Webhook rejected"},
+		{"error", "http://webhook/hook", &mockHttpClient{err: errors.New("refused")}, true, "[first-seen] Cannot send webhook"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			str.Reset()
+			os.Setenv("K8S_PACKET_FIRST_SEEN_WEBHOOK_URL", test.url)
+
+			service := &Service{webhookClient: test.client}
+			service.notify(model.Destination{Namespace: "ns", Kind: kindIP, Destination: "1.1.1.1"})
+
+			assert.EqualValues(t, test.sent, test.client.request != nil)
+			if test.sent {
+				var destination model.Destination
+				json.Unmarshal(test.client.body, &destination)
+				assert.EqualValues(t, http.MethodPost, test.client.request.Method)
+				assert.EqualValues(t, "application/json", test.client.request.Header.Get("Content-Type"))
+				assert.EqualValues(t, "1.1.1.1", destination.Destination)
+			}
+			assert.Contains(t, str.String(), test.err)
+		})
+	}
+}
+
+func TestRegisterQueuesNotifications(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	t.Setenv("K8S_PACKET_FIRST_SEEN_WEBHOOK_URL", "http://webhook/hook")
+
+	mockRepository := &mockRepository{stored: make(map[string]model.Destination)}
+	service := &Service{repo: mockRepository, seen: make(map[string]bool), notifications: make(chan model.Destination, 1)}
+
+	client := modules.Address{Namespace: "ns", Name: "client"}
+
+	service.register(client, kindSNI, "k8spacket.io")
+	service.register(client, kindSNI, "github.com")
+
+	assert.Len(t, service.notifications, 1)
+	assert.EqualValues(t, "k8spacket.io", (<-service.notifications).Destination)
+	assert.Contains(t, str.String(), "[first-seen] Webhook queue is full, notification dropped")
+	assert.Contains(t, str.String(), "destination=github.com")
+}
+
+func TestRegisterCacheSize(t *testing.T) {
+
+	defer func(size int) { seenCacheSize = size }(seenCacheSize)
+	seenCacheSize = 2
+
+	mockRepository := &mockRepository{stored: make(map[string]model.Destination)}
+	service := &Service{repo: mockRepository, seen: make(map[string]bool)}
+
+	client := modules.Address{Namespace: "ns", Name: "client"}
+
+	service.register(client, kindIP, "1.1.1.1")
+	service.register(client, kindIP, "2.2.2.2")
+	service.register(client, kindIP, "3.3.3.3")
+
+	assert.Len(t, service.seen, 1)
+	assert.Len(t, mockRepository.stored, 3)
+
+	// known again after the cache was started over, from the database
+	service.register(client, kindIP, "1.1.1.1")
+
+	assert.Len(t, mockRepository.stored, 3)
+	assert.EqualValues(t, 4, mockRepository.reads)
+}
+
+func TestCacheSize(t *testing.T) {
+	assert.EqualValues(t, 10000, cacheSize(""))
+	assert.EqualValues(t, 10000, cacheSize("-1"))
+	assert.EqualValues(t, 50, cacheSize("50"))
+}
+
+func TestBuildDestinationsResponse(t *testing.T) {
+
+	earlier := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	httpClient := &mockHttpClient{responses: map[string][]model.Destination{
+		"10.0.0.1": {{Id: "1", Namespace: "ns", Destination: "k8spacket.io", Workload: "a", FirstSeen: later}, {Id: "2", Namespace: "ns", Destination: "github.com", FirstSeen: later}},
+		"10.0.0.2": {{Id: "1", Namespace: "ns", Destination: "k8spacket.io", Workload: "b", FirstSeen: earlier}},
+	}}
+	service := &Service{httpClient: httpClient, k8sClient: &mockK8SClient{ips: []string{"10.0.0.1", "10.0.0.2"}}}
+
+	result := service.buildDestinationsResponse(context.Background(), "http://%s:6676/firstseen/destinations?namespace=ns")
+
+	assert.EqualValues(t, []model.Destination{
+		{Id: "1", Namespace: "ns", Destination: "k8spacket.io", Workload: "b", FirstSeen: earlier},
+		{Id: "2", Namespace: "ns", Destination: "github.com", FirstSeen: later}}, result)
+	assert.EqualValues(t, "/firstseen/destinations", httpClient.request.URL.Path)
+}
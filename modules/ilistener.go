@@ -1,5 +1,14 @@
 package modules
 
-type IListener[T TCPEvent | TLSEvent] interface {
+type IListener[T TCPEvent | TLSEvent | LoopbackEvent] interface {
 	Listen(event T)
 }
+
+// passes the event to many listeners, e.g. modules interested in the same type of events
+type Listeners[T TCPEvent | TLSEvent | LoopbackEvent] []IListener[T]
+
+func (listeners Listeners[T]) Listen(event T) {
+	for _, listener := range listeners {
+		listener.Listen(event)
+	}
+}
@@ -0,0 +1,51 @@
+package loopback
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/k8spacket/k8spacket/external/db"
+)
+
+type Controller struct {
+	service IService
+}
+
+// loopback connections seen by this k8spacket instance
+func (controller *Controller) ConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	patternNs, err := regexp.Compile(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prepareResponse(w, controller.service.getConnections(patternNs))
+}
+
+// loopback connections seen by any k8spacket instance in the cluster
+func (controller *Controller) ClusterConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := regexp.Compile(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	budget := db.NewBudget()
+	connections := controller.service.buildConnectionsResponse(db.WithBudget(r.Context(), budget), fmt.Sprintf("http://%%s:%s/loopback/connections?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), r.URL.Query().Encode()))
+	budget.SetHeader(w.Header())
+
+	prepareResponse(w, connections)
+}
+
+func prepareResponse(w http.ResponseWriter, connections any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(connections)
+	if err != nil {
+		slog.Error("[api] Cannot prepare loopback connections response", "Error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
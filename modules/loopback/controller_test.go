@@ -0,0 +1,71 @@
+package loopback
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/loopback/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockService struct {
+	IService
+	urls []string
+}
+
+func (mock *mockService) add(event modules.LoopbackEvent) {
+}
+
+func (mock *mockService) getConnections(patternNs *regexp.Regexp) []model.Connection {
+	return []model.Connection{{Namespace: "shop", Process: "envoy", Port: 8080}}
+}
+
+func (mock *mockService) buildConnectionsResponse(ctx context.Context, url string) []model.Connection {
+	mock.urls = append(mock.urls, url)
+	return []model.Connection{{Namespace: "shop", Process: "envoy", Port: 8080}}
+}
+
+func TestConnectionsHandler(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		query    string
+		status   int
+		body     string
+		url      []string
+	}{
+		{"ok", "namespace=shop", http.StatusOK, "\"process\":\"envoy\"", []string{"http://%s:6676/loopback/connections?namespace=shop"}},
+		{"wrong regexp", "namespace=(", http.StatusBadRequest, "error parsing regexp", nil},
+	}
+
+	t.Setenv("K8S_PACKET_TCP_LISTENER_PORT", "6676")
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			service := &mockService{}
+			controller := &Controller{service}
+
+			req, _ := http.NewRequest(http.MethodGet, "/loopback/connections?"+test.query, nil)
+			rr := httptest.NewRecorder()
+
+			controller.ConnectionsHandler(rr, req)
+
+			assert.EqualValues(t, test.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), test.body)
+
+			req, _ = http.NewRequest(http.MethodGet, "/loopback/api/connections?"+test.query, nil)
+			rr = httptest.NewRecorder()
+
+			controller.ClusterConnectionsHandler(rr, req)
+
+			assert.EqualValues(t, test.status, rr.Code)
+			assert.Contains(t, rr.Body.String(), test.body)
+			assert.EqualValues(t, test.url, service.urls)
+		})
+	}
+}
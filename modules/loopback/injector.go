@@ -0,0 +1,30 @@
+package loopback
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/loopback/model"
+)
+
+// loopback connections are captured on demand only (K8S_PACKET_LOOPBACK_ENABLED), they are kept in memory of the agent
+func Init(mux *http.ServeMux) modules.IListener[modules.LoopbackEvent] {
+
+	enabled, _ := strconv.ParseBool(os.Getenv("K8S_PACKET_LOOPBACK_ENABLED"))
+	if !enabled {
+		return modules.Listeners[modules.LoopbackEvent]{}
+	}
+
+	service := &Service{httpClient: &httpclient.HttpClient{}, k8sClient: &k8sclient.K8SClient{}, connections: make(map[string]model.Connection)}
+	controller := &Controller{service}
+
+	mux.HandleFunc("/loopback/connections", controller.ConnectionsHandler)
+	mux.HandleFunc("/loopback/api/connections", controller.ClusterConnectionsHandler)
+
+	return &Listener{service}
+
+}
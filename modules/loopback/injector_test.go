@@ -0,0 +1,32 @@
+package loopback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit(t *testing.T) {
+
+	mux := http.NewServeMux()
+	listener := Init(mux)
+
+	assert.IsType(t, modules.Listeners[modules.LoopbackEvent]{}, listener)
+
+	t.Setenv("K8S_PACKET_LOOPBACK_ENABLED", "true")
+
+	mux = http.NewServeMux()
+	listener = Init(mux)
+
+	listener.Listen(loopbackEvent("shop", "envoy", 10, 8080))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/loopback/connections", nil)
+	mux.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), "\"process\":\"envoy\"")
+	assert.Contains(t, rr.Body.String(), "\"connections\":1")
+}
@@ -0,0 +1,17 @@
+package loopback
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/loopback/model"
+)
+
+type IService interface {
+	add(event modules.LoopbackEvent)
+
+	getConnections(patternNs *regexp.Regexp) []model.Connection
+
+	buildConnectionsResponse(ctx context.Context, url string) []model.Connection
+}
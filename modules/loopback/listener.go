@@ -0,0 +1,13 @@
+package loopback
+
+import (
+	"github.com/k8spacket/k8spacket/modules"
+)
+
+type Listener struct {
+	service IService
+}
+
+func (listener *Listener) Listen(event modules.LoopbackEvent) {
+	listener.service.add(event)
+}
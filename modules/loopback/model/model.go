@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// connections inside a pod over the loopback interface, grouped by the connecting process and the server port
+type Connection struct {
+	Id            string    `json:"id"`
+	Namespace     string    `json:"namespace"`
+	Name          string    `json:"name"`
+	Process       string    `json:"process"`
+	Pid           uint32    `json:"pid"`
+	Port          uint16    `json:"port"`
+	Connections   uint64    `json:"connections"`
+	BytesSent     uint64    `json:"bytesSent"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	Duration      uint64    `json:"duration"`
+	LastSeen      time.Time `json:"lastSeen"`
+}
@@ -0,0 +1,109 @@
+package loopback
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/fanout"
+	"github.com/k8spacket/k8spacket/external/hashid"
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/loopback/model"
+)
+
+type Service struct {
+	httpClient  httpclient.IHttpClient
+	k8sClient   k8sclient.IK8SClient
+	connections map[string]model.Connection
+	mutex       sync.Mutex
+}
+
+// groups of connections kept in memory, K8S_PACKET_LOOPBACK_MAX_CONNECTIONS (10000 by default)
+var maxConnections = connectionsLimit(os.Getenv("K8S_PACKET_LOOPBACK_MAX_CONNECTIONS"))
+
+func connectionsLimit(value string) int {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 10000
+	}
+	return limit
+}
+
+// both ends of a loopback connection are in the same pod, so connections are grouped by the pod,
+// the connecting process and the server port, the client port is ephemeral
+func (service *Service) add(event modules.LoopbackEvent) {
+	var id = strconv.Itoa(int(hashid.HashId(fmt.Sprintf("%s-%s-%s-%d", event.Server.Namespace, event.Server.Name, event.Process.Comm, event.Server.Port))))
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	connection, ok := service.connections[id]
+	if !ok {
+		// known groups are still counted when the limit is reached, new ones are skipped
+		if len(service.connections) >= maxConnections {
+			return
+		}
+		connection = model.Connection{Id: id, Namespace: event.Server.Namespace, Name: event.Server.Name, Process: event.Process.Comm, Port: event.Server.Port}
+	}
+	connection.Pid = event.Process.Pid
+	connection.Connections++
+	connection.BytesSent += event.TxB
+	connection.BytesReceived += event.RxB
+	connection.Duration += event.DeltaUs
+	connection.LastSeen = time.Now()
+	service.connections[id] = connection
+}
+
+func (service *Service) getConnections(patternNs *regexp.Regexp) []model.Connection {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	var result = []model.Connection{}
+	for _, connection := range service.connections {
+		if patternNs.MatchString(connection.Namespace) {
+			result = append(result, connection)
+		}
+	}
+	sortConnections(result)
+	return result
+}
+
+// loopback connections of a pod are seen by the k8spacket instance of its node only,
+// groups of processes outside of pods (e.g. of the node itself) can be reported by many instances and are summed up
+func (service *Service) buildConnectionsResponse(ctx context.Context, url string) []model.Connection {
+	resultFunc := func(destination, source []model.Connection) []model.Connection {
+		merged := make(map[string]model.Connection)
+		for _, connection := range slices.Concat(destination, source) {
+			if existing, ok := merged[connection.Id]; ok {
+				connection.Connections += existing.Connections
+				connection.BytesSent += existing.BytesSent
+				connection.BytesReceived += existing.BytesReceived
+				connection.Duration += existing.Duration
+				if existing.LastSeen.After(connection.LastSeen) {
+					connection.Pid = existing.Pid
+					connection.LastSeen = existing.LastSeen
+				}
+			}
+			merged[connection.Id] = connection
+		}
+		return slices.Collect(maps.Values(merged))
+	}
+	connections := fanout.Get(ctx, service.httpClient, service.k8sClient, url, []model.Connection{}, resultFunc)
+	sortConnections(connections)
+	return connections
+}
+
+func sortConnections(connections []model.Connection) {
+	slices.SortFunc(connections, func(a, b model.Connection) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name), cmp.Compare(a.Process, b.Process), cmp.Compare(a.Port, b.Port))
+	})
+}
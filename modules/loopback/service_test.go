@@ -0,0 +1,120 @@
+package loopback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/loopback/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHttpClient struct {
+	request   *http.Request
+	responses map[string][]model.Connection
+	mutex     sync.Mutex
+}
+
+func (mock *mockHttpClient) Do(req *http.Request) (*http.Response, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.request = req
+	result, _ := json.Marshal(mock.responses[req.URL.Hostname()])
+	return &http.Response{Body: io.NopCloser(bytes.NewBuffer(result)), StatusCode: http.StatusOK}, nil
+}
+
+type mockK8SClient struct {
+	k8sclient.IK8SClient
+	ips []string
+}
+
+func (mock *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
+	return mock.ips, nil
+}
+
+func loopbackEvent(namespace string, comm string, pid uint32, port uint16) modules.LoopbackEvent {
+	pod := modules.Address{Addr: "127.0.0.1", Name: "pod.backend-1", Namespace: namespace}
+	client, server := pod, pod
+	client.Port = 41000
+	server.Port = port
+	return modules.LoopbackEvent{Client: client, Server: server, Process: modules.Process{Pid: pid, Comm: comm}, TxB: 10, RxB: 20, DeltaUs: 5}
+}
+
+func TestAdd(t *testing.T) {
+
+	service := &Service{connections: make(map[string]model.Connection)}
+
+	service.add(loopbackEvent("shop", "envoy", 10, 8080))
+	service.add(loopbackEvent("shop", "envoy", 11, 8080))
+	service.add(loopbackEvent("shop", "envoy", 11, 9090))
+	service.add(loopbackEvent("other", "curl", 12, 8080))
+
+	result := service.getConnections(regexp.MustCompile("shop"))
+
+	assert.Len(t, result, 2)
+	assert.EqualValues(t, "pod.backend-1", result[0].Name)
+	assert.EqualValues(t, "envoy", result[0].Process)
+	assert.EqualValues(t, 8080, result[0].Port)
+	assert.EqualValues(t, 11, result[0].Pid)
+	assert.EqualValues(t, 2, result[0].Connections)
+	assert.EqualValues(t, 20, result[0].BytesSent)
+	assert.EqualValues(t, 40, result[0].BytesReceived)
+	assert.EqualValues(t, 10, result[0].Duration)
+	assert.False(t, result[0].LastSeen.IsZero())
+	assert.EqualValues(t, 9090, result[1].Port)
+
+	assert.Len(t, service.getConnections(regexp.MustCompile("")), 3)
+}
+
+func TestAddLimit(t *testing.T) {
+
+	defer func(limit int) { maxConnections = limit }(maxConnections)
+	maxConnections = 1
+
+	service := &Service{connections: make(map[string]model.Connection)}
+
+	service.add(loopbackEvent("shop", "envoy", 10, 8080))
+	service.add(loopbackEvent("shop", "envoy", 10, 9090))
+	service.add(loopbackEvent("shop", "envoy", 10, 8080))
+
+	result := service.getConnections(regexp.MustCompile(""))
+
+	assert.Len(t, result, 1)
+	assert.EqualValues(t, 8080, result[0].Port)
+	assert.EqualValues(t, 2, result[0].Connections)
+}
+
+func TestConnectionsLimit(t *testing.T) {
+	assert.EqualValues(t, 10000, connectionsLimit(""))
+	assert.EqualValues(t, 10000, connectionsLimit("0"))
+	assert.EqualValues(t, 50, connectionsLimit("50"))
+}
+
+func TestBuildConnectionsResponse(t *testing.T) {
+
+	earlier := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	httpClient := &mockHttpClient{responses: map[string][]model.Connection{
+		"10.0.0.1": {{Id: "1", Namespace: "shop", Name: "pod.backend-1", Process: "envoy", Port: 8080, Connections: 2, BytesSent: 10, LastSeen: later},
+			{Id: "2", Process: "kubelet", Pid: 100, Port: 10248, Connections: 1, LastSeen: earlier}},
+		"10.0.0.2": {{Id: "2", Process: "kubelet", Pid: 200, Port: 10248, Connections: 3, LastSeen: later}},
+	}}
+	service := &Service{httpClient: httpClient, k8sClient: &mockK8SClient{ips: []string{"10.0.0.1", "10.0.0.2"}}}
+
+	result := service.buildConnectionsResponse(context.Background(), "http://%s:6676/loopback/connections?namespace=")
+
+	assert.EqualValues(t, []model.Connection{
+		{Id: "2", Process: "kubelet", Pid: 200, Port: 10248, Connections: 4, LastSeen: later},
+		{Id: "1", Namespace: "shop", Name: "pod.backend-1", Process: "envoy", Port: 8080, Connections: 2, BytesSent: 10, LastSeen: later}}, result)
+	assert.EqualValues(t, "/loopback/connections", httpClient.request.URL.Path)
+}
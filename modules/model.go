@@ -1,10 +1,11 @@
 package modules
 
 type Address struct {
-	Addr      string
-	Port      uint16
-	Name      string
-	Namespace string
+	Addr       string
+	Port       uint16
+	Name       string
+	Namespace  string
+	WorkloadId string
 }
 type TCPEvent struct {
 	Client  Address
@@ -12,6 +13,15 @@ type TCPEvent struct {
 	TxB     uint64
 	RxB     uint64
 	DeltaUs uint64
+	// connections summed by the event, more than one for flows aggregated in the kernel (K8S_PACKET_FLOW_AGGREGATION_INTERVAL),
+	// TxB, RxB and DeltaUs are sums then and MaxDeltaUs is the longest connection
+	Count      uint64
+	MaxDeltaUs uint64
+	// observed on the client side of the connection, otherwise on the server side, connections between nodes are observed on both
+	ClientSide bool
+	// the client is the original one conveyed by a trusted proxy (PROXY protocol), Proxy is the observed client then
+	Proxied bool
+	Proxy   Address
 }
 
 type TLSEvent struct {
@@ -22,4 +32,24 @@ type TLSEvent struct {
 	ServerName     string
 	UsedTlsVersion uint16
 	UsedCipher     uint16
+	// the client is the original one conveyed by a trusted proxy (PROXY protocol), Proxy is the observed client then
+	Proxied bool
+	Proxy   Address
+}
+
+// process which opened the connection
+type Process struct {
+	Pid  uint32
+	Comm string
+}
+
+// connection inside a pod over the loopback interface (e.g. sidecar to app), it is not a network edge between workloads,
+// so it is kept apart from TCPEvent, the client and the server are the same pod
+type LoopbackEvent struct {
+	Client  Address
+	Server  Address
+	Process Process
+	TxB     uint64
+	RxB     uint64
+	DeltaUs uint64
 }
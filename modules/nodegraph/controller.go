@@ -1,6 +1,7 @@
 package nodegraph
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/k8spacket/k8spacket/external/db"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
 )
 
@@ -17,9 +19,11 @@ type Controller struct {
 }
 
 func (controller *Controller) ConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	budget := db.NewBudget()
 	connectionItemsMutex.RLock()
-	var response = filterConnections(controller, r.URL.Query())
+	var response = filterConnections(db.WithBudget(r.Context(), budget), controller, r.URL.Query())
 	connectionItemsMutex.RUnlock()
+	budget.SetHeader(w.Header())
 
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(response)
@@ -29,7 +33,7 @@ func (controller *Controller) ConnectionHandler(w http.ResponseWriter, r *http.R
 	}
 }
 
-func filterConnections(controller *Controller, query url.Values) []model.ConnectionItem {
+func filterConnections(ctx context.Context, controller *Controller, query url.Values) []model.ConnectionItem {
 	var from = query["from"]
 	var rangeFrom = time.Time{}
 	if len(from) > 0 {
@@ -68,5 +72,7 @@ func filterConnections(controller *Controller, query url.Values) []model.Connect
 		patternEx = regexp.MustCompile(exclude[0])
 	}
 
-	return controller.service.getConnections(rangeFrom, rangeTo, patternNs, patternIn, patternEx)
+	var showDeleted, _ = strconv.ParseBool(query.Get("show-deleted"))
+
+	return controller.service.getConnections(ctx, rangeFrom, rangeTo, patternNs, patternIn, patternEx, showDeleted)
 }
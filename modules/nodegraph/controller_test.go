@@ -1,6 +1,7 @@
 package nodegraph
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/k8spacket/k8spacket/external/db"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,14 +25,23 @@ type mockService struct {
 	from, to                        time.Time
 	patternNs, patternIn, patternEx string
 	client, server                  string
+	showDeleted                     bool
+	partial                         bool
+	clientSide                      bool
+	persistent                      bool
+	connections                     uint64
 }
 
-func (mockService *mockService) getConnections(from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp) []model.ConnectionItem {
+func (mockService *mockService) getConnections(ctx context.Context, from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp, showDeleted bool) []model.ConnectionItem {
 	mockService.from = from
 	mockService.to = to
 	mockService.patternNs = patternNs.String()
 	mockService.patternIn = patternIn.String()
 	mockService.patternEx = patternEx.String()
+	mockService.showDeleted = showDeleted
+	if mockService.partial {
+		db.BudgetFrom(ctx).Exceed()
+	}
 	return repo
 }
 
@@ -56,6 +67,7 @@ func TestConnectionHandler(t *testing.T) {
 	q.Add("namespace", "ns")
 	q.Add("include", "in")
 	q.Add("exclude", "ex")
+	q.Add("show-deleted", "true")
 	req.URL.RawQuery = q.Encode()
 
 	rr := httptest.NewRecorder()
@@ -75,5 +87,20 @@ func TestConnectionHandler(t *testing.T) {
 	assert.EqualValues(t, "ns", service.patternNs)
 	assert.EqualValues(t, "in", service.patternIn)
 	assert.EqualValues(t, "ex", service.patternEx)
+	assert.EqualValues(t, true, service.showDeleted)
+	assert.Empty(t, rr.Header().Get(db.PartialResultHeader))
+
+}
+
+func TestConnectionHandlerPartialResult(t *testing.T) {
+
+	controller := &Controller{service: &mockService{partial: true}}
+
+	req, _ := http.NewRequest("GET", "/nodegraph/connections", nil)
+	rr := httptest.NewRecorder()
+
+	controller.ConnectionHandler(rr, req)
 
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "true", rr.Header().Get(db.PartialResultHeader))
 }
@@ -29,6 +29,10 @@ func Init(mux *http.ServeMux) modules.IListener[modules.TCPEvent] {
 	mux.HandleFunc("/nodegraph/api/health", o11yController.Health)
 	mux.HandleFunc("/nodegraph/api/graph/fields", o11yController.NodeGraphFieldsHandler)
 	mux.HandleFunc("/nodegraph/api/graph/data", o11yController.NodeGraphDataHandler)
+	mux.HandleFunc("/nodegraph/api/ipsets/external", o11yController.ExternalIPSetHandler)
+
+	go service.collectGarbage()
+	go service.syncNftables()
 
 	listener := &Listener{service}
 
@@ -1,6 +1,8 @@
 package nodegraph
 
 import (
+	"context"
+	"github.com/k8spacket/k8spacket/modules"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	"net/http"
 	"regexp"
@@ -8,9 +10,12 @@ import (
 )
 
 type IService interface {
-	update(src string, srcName string, srcNamespace string, dst string, dstName string, dstNamespace string, persistent bool, bytesSent float64, bytesReceived float64, duration float64)
-	getConnections(from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp) []model.ConnectionItem
+	update(src modules.Address, dst modules.Address, clientSide bool, persistent bool, connections uint64, bytesSent float64, bytesReceived float64, duration float64, maxDuration float64)
+	getConnections(ctx context.Context, from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp, showDeleted bool) []model.ConnectionItem
+	collectGarbage()
 
 	getO11yStatsConfig(statsType string) (string, error)
 	buildO11yResponse(r *http.Request) (model.NodeGraph, error)
+	buildExternalIPSet(r *http.Request) model.IPSet
+	syncNftables()
 }
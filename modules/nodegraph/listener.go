@@ -18,13 +18,14 @@ func (listener *Listener) Listen(event modules.TCPEvent) {
 
 	var persistent = false
 	var persistentDuration, _ = time.ParseDuration(os.Getenv("K8S_PACKET_TCP_PERSISTENT_DURATION"))
-	if int(event.DeltaUs) > int(persistentDuration.Milliseconds()) {
+	// flows aggregated in the kernel keep persistent and other connections apart, so the average duration decides for all of them
+	if int(event.DeltaUs/max(event.Count, 1)) > int(persistentDuration.Milliseconds()) {
 		persistent = true
 	}
 
 	sendPrometheusMetrics(event, persistent)
 
-	listener.service.update(event.Client.Addr, event.Client.Name, event.Client.Namespace, event.Server.Addr, event.Server.Name, event.Server.Namespace, persistent, float64(event.TxB), float64(event.RxB), float64(event.DeltaUs))
+	listener.service.update(event.Client, event.Server, event.ClientSide, persistent, event.Count, float64(event.TxB), float64(event.RxB), float64(event.DeltaUs), float64(event.MaxDeltaUs))
 
 	slog.Info("Connection",
 		"src", event.Client.Addr,
@@ -36,6 +37,7 @@ func (listener *Listener) Listen(event modules.TCPEvent) {
 		"dstPort", strconv.Itoa(int(event.Server.Port)),
 		"dstNS", event.Server.Namespace,
 		"persistent", persistent,
+		"connections", event.Count,
 		"bytesSent", float64(event.TxB),
 		"bytesReceived", float64(event.RxB),
 		"duration", float64(event.DeltaUs))
@@ -44,10 +46,15 @@ func (listener *Listener) Listen(event modules.TCPEvent) {
 func sendPrometheusMetrics(event modules.TCPEvent, persistent bool) {
 	hideSrcPort, _ := strconv.ParseBool(os.Getenv("K8S_PACKET_TCP_METRICS_HIDE_SRC_PORT"))
 	var srcPortMetrics = strconv.Itoa(int(event.Client.Port))
-	if hideSrcPort {
+	// the client port of connections summed in a flow is not kept
+	if hideSrcPort || event.Client.Port == 0 {
 		srcPortMetrics = "dynamic"
 	}
-	prometheus.K8sPacketBytesSentMetric.WithLabelValues(event.Client.Namespace, event.Client.Addr, event.Client.Name, srcPortMetrics, event.Server.Addr, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Observe(float64(event.TxB))
-	prometheus.K8sPacketBytesReceivedMetric.WithLabelValues(event.Client.Namespace, event.Client.Addr, event.Client.Name, srcPortMetrics, event.Server.Addr, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Observe(float64(event.RxB))
-	prometheus.K8sPacketDurationSecondsMetric.WithLabelValues(event.Client.Namespace, event.Client.Addr, event.Client.Name, srcPortMetrics, event.Server.Addr, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Observe(float64(event.DeltaUs))
+	// a flow aggregated in the kernel is observed once, with the average values of its connections
+	count := float64(max(event.Count, 1))
+	prometheus.K8sPacketBytesSentMetric.WithLabelValues(event.Client.Namespace, event.Client.Addr, event.Client.Name, srcPortMetrics, event.Server.Addr, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Observe(float64(event.TxB) / count)
+	prometheus.K8sPacketBytesReceivedMetric.WithLabelValues(event.Client.Namespace, event.Client.Addr, event.Client.Name, srcPortMetrics, event.Server.Addr, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Observe(float64(event.RxB) / count)
+	prometheus.K8sPacketDurationSecondsMetric.WithLabelValues(event.Client.Namespace, event.Client.Addr, event.Client.Name, srcPortMetrics, event.Server.Addr, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Observe(float64(event.DeltaUs) / count)
+	// labeled by names without IPs and source ports, which would split connections of an edge into many series
+	prometheus.K8sPacketConnectionsMetric.WithLabelValues(event.Client.Namespace, event.Client.Name, event.Server.Namespace, event.Server.Name, strconv.Itoa(int(event.Server.Port)), strconv.FormatBool(persistent)).Add(float64(event.Count))
 }
@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"log/slog"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/nodegraph/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
-func (mockService *mockService) update(src string, srcName string, srcNamespace string, dst string, dstName string, dstNamespace string, persistent bool, bytesSent float64, bytesReceived float64, duration float64) {
-	mockService.client = src
-	mockService.server = dst
+func (mockService *mockService) update(src modules.Address, dst modules.Address, clientSide bool, persistent bool, connections uint64, bytesSent float64, bytesReceived float64, duration float64, maxDuration float64) {
+	mockService.client = src.Addr
+	mockService.server = dst.Addr
+	mockService.clientSide = clientSide
+	mockService.persistent = persistent
+	mockService.connections = connections
 }
 
 func TestListen(t *testing.T) {
@@ -29,12 +35,45 @@ func TestListen(t *testing.T) {
 	service := &mockService{}
 	listener := &Listener{service}
 
-	event := modules.TCPEvent{Client: modules.Address{Addr: "client"}, Server: modules.Address{Addr: "server"}, DeltaUs: 2}
+	event := modules.TCPEvent{Client: modules.Address{Addr: "client"}, Server: modules.Address{Addr: "server"}, DeltaUs: 2, MaxDeltaUs: 2, Count: 1, ClientSide: true}
 	listener.Listen(event)
 
 	assert.EqualValues(t, event.Client.Addr, service.client)
 	assert.EqualValues(t, event.Server.Addr, service.server)
+	assert.True(t, service.clientSide)
 
-	assert.Contains(t, str.String(), "Connection src=client srcName=\"\" srcPort=0 srcNS=\"\" dst=server dstName=\"\" dstPort=0 dstNS=\"\" persistent=true bytesSent=0 bytesReceived=0 duration=2")
+	assert.EqualValues(t, 1, testutil.ToFloat64(prometheus.K8sPacketConnectionsMetric.WithLabelValues("", "", "", "", "0", "true")))
 
+	assert.Contains(t, str.String(), "Connection src=client srcName=\"\" srcPort=0 srcNS=\"\" dst=server dstName=\"\" dstPort=0 dstNS=\"\" persistent=true connections=1 bytesSent=0 bytesReceived=0 duration=2")
+
+}
+
+func TestListenFlow(t *testing.T) {
+
+	os.Setenv("K8S_PACKET_TCP_PERSISTENT_DURATION", "1ms")
+
+	var tests = []struct {
+		scenario   string
+		deltaUs    uint64
+		persistent bool
+	}{
+		{"persistent", 8, true},
+		{"short", 4, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			service := &mockService{}
+			listener := &Listener{service}
+
+			// summary of 4 connections of a flow aggregated in the kernel
+			listener.Listen(modules.TCPEvent{Client: modules.Address{Addr: "client"}, Server: modules.Address{Addr: "server", Namespace: "flow-" + test.scenario},
+				DeltaUs: test.deltaUs, MaxDeltaUs: 3, Count: 4})
+
+			assert.EqualValues(t, test.persistent, service.persistent)
+			assert.EqualValues(t, 4, service.connections)
+			assert.EqualValues(t, 4, testutil.ToFloat64(prometheus.K8sPacketConnectionsMetric.WithLabelValues("", "", "flow-"+test.scenario, "", "0", strconv.FormatBool(test.persistent))))
+		})
+	}
 }
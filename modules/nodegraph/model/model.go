@@ -3,9 +3,11 @@ package model
 import "time"
 
 type ConnectionItem struct {
+	SrcId          string    `json:"srcId"`
 	Src            string    `json:"src"`
 	SrcName        string    `json:"srcName"`
 	SrcNamespace   string    `json:"srcNamespace"`
+	DstId          string    `json:"dstId"`
 	Dst            string    `json:"dst"`
 	DstName        string    `json:"dstName"`
 	DstNamespace   string    `json:"dstNamespace"`
@@ -15,7 +17,16 @@ type ConnectionItem struct {
 	BytesReceived  float64   `json:"bytesReceived"`
 	Duration       float64   `json:"duration"`
 	MaxDuration    float64   `json:"maxDuration"`
+	FirstSeen      time.Time `json:"firstSeen"`
 	LastSeen       time.Time `json:"lastSeen"`
+	DeletedAt      time.Time `json:"deletedAt"`
+	Ingress        string    `json:"ingress,omitempty"`
+	ClientSide     bool      `json:"clientSide"`
+}
+
+type IPSet struct {
+	Name     string   `json:"name"`
+	Elements []string `json:"elements"`
 }
 
 type ConnectionEndpoint struct {
@@ -28,6 +39,11 @@ type ConnectionEndpoint struct {
 	BytesReceived  float64
 	Duration       float64
 	MaxDuration    float64
+	FirstSeen      time.Time
+	LastSeen       time.Time
+	// outbound connections, used by the reuse stats only, other stats count inbound connections of servers
+	ClientConnCount int64
+	ClientDuration  float64
 }
 
 type NodeGraph struct {
@@ -64,4 +80,5 @@ type Edge struct {
 	Target        string `json:"target"`
 	MainStat      string `json:"mainStat"`
 	SecondaryStat string `json:"secondaryStat"`
+	DetailIngress string `json:"detail__ingress"`
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+
+	"github.com/k8spacket/k8spacket/external/db"
 )
 
 type O11yController struct {
@@ -29,12 +31,28 @@ func (o11yController *O11yController) NodeGraphFieldsHandler(w http.ResponseWrit
 	w.Write([]byte(response))
 }
 
+func (o11yController *O11yController) ExternalIPSetHandler(w http.ResponseWriter, r *http.Request) {
+	budget := db.NewBudget()
+	ipSet := o11yController.service.buildExternalIPSet(r.WithContext(db.WithBudget(r.Context(), budget)))
+	budget.SetHeader(w.Header())
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(ipSet)
+	if err != nil {
+		slog.Error("[api] Cannot prepare ip set response", "Error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func (o11yController *O11yController) NodeGraphDataHandler(w http.ResponseWriter, r *http.Request) {
-	nodegraph, err := o11yController.service.buildO11yResponse(r)
+	budget := db.NewBudget()
+	nodegraph, err := o11yController.service.buildO11yResponse(r.WithContext(db.WithBudget(r.Context(), budget)))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	budget.SetHeader(w.Header())
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -33,6 +33,10 @@ func (mockService *mockService) buildO11yResponse(r *http.Request) (model.NodeGr
 	return response, nil
 }
 
+func (mockService *mockService) buildExternalIPSet(r *http.Request) model.IPSet {
+	return model.IPSet{Name: "external", Elements: []string{"8.8.8.8"}}
+}
+
 func TestHealth(t *testing.T) {
 
 	o11yController := &O11yController{service: &Service{}}
@@ -125,3 +129,22 @@ func TestNodeGraphDataHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestExternalIPSetHandler(t *testing.T) {
+
+	service := &mockService{}
+	o11yController := &O11yController{service: service}
+
+	req, err := http.NewRequest("GET", "/nodegraph/api/ipsets/external", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(o11yController.ExternalIPSetHandler)
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"name":"external","elements":["8.8.8.8"]}`, rr.Body.String())
+}
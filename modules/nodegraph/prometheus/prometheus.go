@@ -28,6 +28,13 @@ var (
 		},
 		[]string{"ns", "src", "src_name", "src_port", "dst", "dst_name", "dst_port", "persistent"},
 	)
+	K8sPacketConnectionsMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8s_packet_connections",
+			Help: "Kubernetes packet connections between workloads, the persistent share shows connection reuse",
+		},
+		[]string{"ns", "src_name", "dst_ns", "dst_name", "dst_port", "persistent"},
+	)
 )
 
 func Init() {
@@ -36,5 +43,6 @@ func Init() {
 		prometheus.MustRegister(K8sPacketBytesSentMetric)
 		prometheus.MustRegister(K8sPacketBytesReceivedMetric)
 		prometheus.MustRegister(K8sPacketDurationSecondsMetric)
+		prometheus.MustRegister(K8sPacketConnectionsMetric)
 	}
 }
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	"regexp"
 	"time"
@@ -8,6 +9,7 @@ import (
 
 type IRepository[T model.ConnectionItem] interface {
 	Read(key string) T
-	Query(from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp) []T
+	Query(ctx context.Context, from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp, showDeleted bool) []T
 	Set(key string, value *T)
+	Delete(key string)
 }
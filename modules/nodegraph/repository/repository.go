@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"log/slog"
 	"regexp"
 	"time"
@@ -22,10 +23,14 @@ func (repository *Repository) Read(key string) model.ConnectionItem {
 	return result
 }
 
-func (repository *Repository) Query(from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp) []model.ConnectionItem {
+func (repository *Repository) Query(ctx context.Context, from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp, showDeleted bool) []model.ConnectionItem {
 
-	query := repository.DbHandler.QueryMatchFunc("Src", func(record *model.ConnectionItem) (bool, error) {
+	query := repository.DbHandler.QueryMatchFunc(ctx, "Src", func(record *model.ConnectionItem) (bool, error) {
 		valid := true
+		if !showDeleted {
+			valid = record.DeletedAt.IsZero() &&
+				valid
+		}
 		if !from.IsZero() {
 			valid = record.LastSeen.After(from) &&
 				valid
@@ -71,3 +76,10 @@ func (repository *Repository) Set(key string, value *model.ConnectionItem) {
 		slog.Error("[db:tcp_connections:Upsert]", "Error", err)
 	}
 }
+
+func (repository *Repository) Delete(key string) {
+	err := repository.DbHandler.Delete(key)
+	if err != nil {
+		slog.Error("[db:tcp_connections:Delete]", "Error", err)
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log/slog"
 	"regexp"
@@ -21,6 +22,7 @@ var dbState = []model.ConnectionItem{
 	model.ConnectionItem{LastSeen: time.Now().Add(time.Hour * 2), DstName: "test"},
 	model.ConnectionItem{LastSeen: time.Now().Add(time.Hour * 2)},
 	model.ConnectionItem{LastSeen: time.Now().Add(time.Hour * 1000)},
+	model.ConnectionItem{LastSeen: time.Now(), DeletedAt: time.Now(), Src: "deleted"},
 }
 
 type mockDBHandler struct {
@@ -46,7 +48,7 @@ func (mock *mockDBHandler) Query(query *bolthold.Query) ([]model.ConnectionItem,
 	return mock.queryResult, nil
 }
 
-func (mock *mockDBHandler) QueryMatchFunc(field string, matchFunc func(*model.ConnectionItem) (bool, error)) bolthold.Query {
+func (mock *mockDBHandler) QueryMatchFunc(ctx context.Context, field string, matchFunc func(*model.ConnectionItem) (bool, error)) bolthold.Query {
 	mock.queryResult = []model.ConnectionItem{}
 	for _, item := range dbState {
 		matched, _ := matchFunc(&item)
@@ -66,6 +68,13 @@ func (mock *mockDBHandler) Upsert(key string, value *model.ConnectionItem) error
 	return nil
 }
 
+func (mock *mockDBHandler) Delete(key string) error {
+	if key == "error" {
+		return errors.New("error")
+	}
+	return nil
+}
+
 func TestRead(t *testing.T) {
 
 	var tests = []struct {
@@ -103,14 +112,16 @@ func TestQuery(t *testing.T) {
 		msg                             string
 		from, to                        time.Time
 		patternNs, patternIn, patternEx *regexp.Regexp
+		showDeleted                     bool
 		want                            []model.ConnectionItem
 		error                           string
 	}{
-		{"from / to filter", time.Now().Add(time.Minute * -1), time.Now().Add(time.Minute), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile(""), dbState[1:2], ""},
-		{"namespace filter", time.Now().Add(time.Hour * -3), time.Now().Add(time.Hour * 3), regexp.MustCompile("^test$"), regexp.MustCompile(""), regexp.MustCompile(""), dbState[1:3], ""},
-		{"include filter", time.Now().Add(time.Hour * -3), time.Now().Add(time.Hour * 3), regexp.MustCompile(""), regexp.MustCompile("test"), regexp.MustCompile(""), dbState[0:4], ""},
-		{"exclude filter", time.Now().Add(time.Hour * -3), time.Now().Add(time.Hour * 3), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile("test"), dbState[4:5], ""},
-		{"error", time.Now().Add(time.Hour * 998), time.Now().Add(time.Hour * 1001), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile(""), []model.ConnectionItem{}, "[db:tcp_connections:Query] Error=error"},
+		{"from / to filter", time.Now().Add(time.Minute * -1), time.Now().Add(time.Minute), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile(""), false, dbState[1:2], ""},
+		{"show deleted", time.Now().Add(time.Minute * -1), time.Now().Add(time.Minute), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile(""), true, []model.ConnectionItem{dbState[1], dbState[6]}, ""},
+		{"namespace filter", time.Now().Add(time.Hour * -3), time.Now().Add(time.Hour * 3), regexp.MustCompile("^test$"), regexp.MustCompile(""), regexp.MustCompile(""), false, dbState[1:3], ""},
+		{"include filter", time.Now().Add(time.Hour * -3), time.Now().Add(time.Hour * 3), regexp.MustCompile(""), regexp.MustCompile("test"), regexp.MustCompile(""), false, dbState[0:4], ""},
+		{"exclude filter", time.Now().Add(time.Hour * -3), time.Now().Add(time.Hour * 3), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile("test"), false, dbState[4:5], ""},
+		{"error", time.Now().Add(time.Hour * 998), time.Now().Add(time.Hour * 1001), regexp.MustCompile(""), regexp.MustCompile(""), regexp.MustCompile(""), false, []model.ConnectionItem{}, "[db:tcp_connections:Query] Error=error"},
 	}
 
 	mockDBHandler := &mockDBHandler{}
@@ -120,7 +131,7 @@ func TestQuery(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.msg, func(t *testing.T) {
 
-			result := repository.Query(test.from, test.to, test.patternNs, test.patternIn, test.patternEx)
+			result := repository.Query(context.Background(), test.from, test.to, test.patternNs, test.patternIn, test.patternEx, test.showDeleted)
 
 			assert.EqualValues(t, test.want, result)
 			assert.Contains(t, str.String(), test.error)
@@ -162,3 +173,22 @@ func TestSet(t *testing.T) {
 		})
 	}
 }
+
+func TestDelete(t *testing.T) {
+
+	var str bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&str, nil))
+
+	slog.SetDefault(logger)
+
+	mockDBHandler := &mockDBHandler{}
+
+	repository := Repository{mockDBHandler}
+
+	repository.Delete("key")
+	assert.NotContains(t, str.String(), "[db:tcp_connections:Delete]")
+
+	repository.Delete("error")
+	assert.Contains(t, str.String(), "[db:tcp_connections:Delete] Error=error")
+}
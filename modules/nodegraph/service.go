@@ -1,25 +1,33 @@
 package nodegraph
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/k8spacket/k8spacket/external/db"
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/external/fanout"
 	"github.com/k8spacket/k8spacket/external/handlerio"
+	"github.com/k8spacket/k8spacket/external/hashid"
 	"github.com/k8spacket/k8spacket/external/http"
 	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/repository"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/stats"
+	"github.com/k8spacket/k8spacket/security"
 )
 
 type Service struct {
@@ -32,78 +40,156 @@ type Service struct {
 
 var connectionItemsMutex = sync.RWMutex{}
 
-func (service *Service) update(src string, srcName string, srcNamespace string, dst string, dstName string, dstNamespace string, persistent bool, bytesSent float64, bytesReceived float64, duration float64) {
+// ingress controller pods are looked up at most once per K8S_PACKET_INGRESS_REFRESH_PERIOD, not for every graph request
+var ingressIPs = struct {
+	sync.Mutex
+	ips       []string
+	fetchedAt time.Time
+}{}
+
+const defaultIngressLabelSelectors = "app.kubernetes.io/name=ingress-nginx;app.kubernetes.io/name=traefik;app.kubernetes.io/name=envoy;app.kubernetes.io/component=envoy;istio=ingressgateway"
+
+// connections are stored by workload identifiers, so they are still meaningful after pods get new IPs,
+// and by the side they are observed on, see oneSidePerConnection
+func (service *Service) update(src modules.Address, dst modules.Address, clientSide bool, persistent bool, connections uint64, bytesSent float64, bytesReceived float64, duration float64, maxDuration float64) {
 	connectionItemsMutex.Lock()
-	var id = strconv.Itoa(int(db.HashId(fmt.Sprintf("%s-%s", src, dst))))
+	var id = connectionId(src.WorkloadId, dst.WorkloadId, clientSide)
 	var connection = service.repo.Read(id)
 	if (model.ConnectionItem{} == connection) {
-		connection = *&model.ConnectionItem{Src: src, Dst: dst}
+		connection = *&model.ConnectionItem{SrcId: src.WorkloadId, DstId: dst.WorkloadId, ClientSide: clientSide, FirstSeen: time.Now()}
 	}
-	connection.SrcName = srcName
-	connection.SrcNamespace = srcNamespace
-	connection.DstName = dstName
-	connection.DstNamespace = dstNamespace
-	connection.ConnCount++
+	connection.Src = src.Addr
+	connection.SrcName = src.Name
+	connection.SrcNamespace = src.Namespace
+	connection.Dst = dst.Addr
+	connection.DstName = dst.Name
+	connection.DstNamespace = dst.Namespace
+	connection.ConnCount += int64(connections)
 	if persistent {
-		connection.ConnPersistent++
+		connection.ConnPersistent += int64(connections)
 	}
 	connection.BytesSent += bytesSent
 	connection.BytesReceived += bytesReceived
 	connection.Duration += duration
-	if duration > connection.MaxDuration {
-		connection.MaxDuration = duration
+	if maxDuration > connection.MaxDuration {
+		connection.MaxDuration = maxDuration
 	}
 	connection.LastSeen = time.Now()
+	connection.DeletedAt = time.Time{}
 	service.repo.Set(id, &connection)
 	connectionItemsMutex.Unlock()
 }
 
-func (service *Service) getConnections(from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp) []model.ConnectionItem {
+func (service *Service) getConnections(ctx context.Context, from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp, showDeleted bool) []model.ConnectionItem {
 
 	slog.Info("[api:params]",
 		"patternNs", patternNs,
 		"patternIn", patternIn,
 		"patternEx", patternEx,
 		"from", from.Format(time.DateTime),
-		"to", to.Format(time.DateTime))
+		"to", to.Format(time.DateTime),
+		"showDeleted", showDeleted)
 
-	return service.repo.Query(from, to, patternNs, patternIn, patternEx)
+	return service.repo.Query(ctx, from, to, patternNs, patternIn, patternEx, showDeleted)
 }
 
-func (service *Service) buildO11yResponse(r *http.Request) (model.NodeGraph, error) {
-	var k8spacketIps = service.k8sClient.GetPodIPsBySelectors(os.Getenv("K8S_PACKET_API_FIELD_SELECTOR"), os.Getenv("K8S_PACKET_API_LABEL_SELECTOR"))
-
-	var in []model.ConnectionItem
-	var connectionItems = make(map[string]model.ConnectionItem)
+func (service *Service) collectGarbage() {
+	var refreshPeriod, _ = time.ParseDuration(os.Getenv("K8S_PACKET_TCP_TOMBSTONE_REFRESH_PERIOD"))
+	if refreshPeriod <= 0 {
+		return
+	}
 
-	for _, ip := range k8spacketIps {
-		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s:%s/nodegraph/connections?%s", ip, os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), r.URL.Query().Encode()), nil)
-		resp, err := service.httpClient.Do(req)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		if err != nil {
-			slog.Error("[api] Cannot get stats", "Error", err)
-			continue
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("[gc] Receive signal, exiting...")
+			return
+		case <-time.After(refreshPeriod):
+			service.tombstone(workloadIds(ebpf_tools.K8sInfo()), time.Now())
 		}
+	}
+}
 
-		if resp.StatusCode == http.StatusOK {
+// identifiers of workloads known from the last refresh of k8s resources, nil when there are none yet
+// or getting k8s resources is disabled, so nothing is tombstoned then
+func workloadIds(k8sInfo map[string]k8sclient.IPResourceInfo) map[string]bool {
+	if len(k8sInfo) == 0 {
+		return nil
+	}
+	ids := make(map[string]bool)
+	for _, info := range k8sInfo {
+		ids[info.WorkloadId] = true
+	}
+	return ids
+}
 
-			responseData, err := io.ReadAll(resp.Body)
-			if err != nil {
-				slog.Error("[api] Cannot read stats response", "Error", err)
-				continue
-			}
+// records stored before the side was recorded keep their identifiers as observed on the server side
+func connectionId(srcId string, dstId string, clientSide bool) string {
+	if clientSide {
+		return strconv.Itoa(int(hashid.HashId(fmt.Sprintf("%s-%s-client", srcId, dstId))))
+	}
+	return strconv.Itoa(int(hashid.HashId(fmt.Sprintf("%s-%s", srcId, dstId))))
+}
 
-			err = json.Unmarshal(responseData, &in)
-			if err != nil {
-				slog.Error("[api] Cannot parse stats response", "Error", err)
-				continue
-			}
+// connections of deleted workloads are tombstoned first, hidden from queries without show-deleted,
+// and removed when the grace period (K8S_PACKET_TCP_TOMBSTONE_GRACE_PERIOD, 24h by default) is exceeded
+func (service *Service) tombstone(workloadIds map[string]bool, now time.Time) {
+	if workloadIds == nil {
+		return
+	}
+	var gracePeriod, err = time.ParseDuration(os.Getenv("K8S_PACKET_TCP_TOMBSTONE_GRACE_PERIOD"))
+	if err != nil {
+		gracePeriod = 24 * time.Hour
+	}
+	var all = regexp.MustCompile("")
+
+	// the scan runs without the lock so the event path is not blocked, changed records are read again under the lock
+	// as they could have been updated by new events in the meantime
+	var ids []string
+	for _, connection := range service.repo.Query(context.Background(), time.Time{}, time.Time{}, all, all, all, true) {
+		if changed, _ := tombstoneConnection(&connection, workloadIds, gracePeriod, now); changed {
+			ids = append(ids, connectionId(connection.SrcId, connection.DstId, connection.ClientSide))
+		}
+	}
 
-			for _, element := range in {
-				connectionItems[element.Src+"-"+element.Dst] = element
-			}
+	for _, id := range ids {
+		connectionItemsMutex.Lock()
+		var connection = service.repo.Read(id)
+		if changed, remove := tombstoneConnection(&connection, workloadIds, gracePeriod, now); remove {
+			service.repo.Delete(id)
+		} else if changed {
+			service.repo.Set(id, &connection)
 		}
+		connectionItemsMutex.Unlock()
 	}
+}
+
+// tombstones or revives the connection, returns whether it has changed and whether it has to be removed
+func tombstoneConnection(connection *model.ConnectionItem, workloadIds map[string]bool, gracePeriod time.Duration, now time.Time) (bool, bool) {
+	var exists = workloadExists(connection.SrcId, workloadIds) && workloadExists(connection.DstId, workloadIds)
+	switch {
+	case exists && !connection.DeletedAt.IsZero():
+		connection.DeletedAt = time.Time{}
+		return true, false
+	case !exists && connection.DeletedAt.IsZero():
+		connection.DeletedAt = now
+		return true, false
+	case !exists && connection.DeletedAt.Add(gracePeriod).Before(now):
+		return true, true
+	}
+	return false, false
+}
+
+// external addresses and records without workload identifiers are never tombstoned
+func workloadExists(id string, workloadIds map[string]bool) bool {
+	return id == "" || net.ParseIP(id) != nil || workloadIds[id]
+}
+
+func (service *Service) buildO11yResponse(r *http.Request) (model.NodeGraph, error) {
+	var connectionItems = service.fetchConnections(r)
 
 	var selectedStats = ""
 	if len(r.URL.Query()["stats-type"]) > 0 {
@@ -111,12 +197,240 @@ func (service *Service) buildO11yResponse(r *http.Request) (model.NodeGraph, err
 	}
 	statsImpl := service.factory.GetStats(selectedStats)
 
+	if collapse, _ := strconv.ParseBool(r.URL.Query().Get("collapse-ingress")); collapse {
+		connectionItems = collapseIngress(connectionItems, ingressWorkloadIds(service.getIngressIPs()))
+	}
+
 	var connectionEndpoints = make(map[string]model.ConnectionEndpoint)
 	prepareConnections(connectionItems, connectionEndpoints)
 	return buildApiResponse(connectionItems, connectionEndpoints, statsImpl), nil
 
 }
 
+// connections observed by all k8spacket instances in the cluster, merged by workload identifiers
+func (service *Service) fetchConnections(r *http.Request) map[string]model.ConnectionItem {
+	resultFunc := func(destination, source []model.ConnectionItem) []model.ConnectionItem {
+		return append(destination, source...)
+	}
+	in := fanout.Get(r.Context(), service.httpClient, service.k8sClient, fmt.Sprintf("http://%%s:%s/nodegraph/connections?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), r.URL.Query().Encode()), []model.ConnectionItem{}, resultFunc)
+
+	var observed = make(map[string]model.ConnectionItem)
+	for _, element := range in {
+		// records stored before workload identifiers were introduced
+		if element.SrcId == "" {
+			element.SrcId = element.Src
+		}
+		if element.DstId == "" {
+			element.DstId = element.Dst
+		}
+		var key = fmt.Sprintf("%s-%s-%t", element.SrcId, element.DstId, element.ClientSide)
+		observed[key] = mergeConnections(observed[key], element)
+	}
+	return oneSidePerConnection(observed)
+}
+
+// connections between pods on different nodes are observed by k8spacket instances on both of them, so only one side is counted,
+// the client side, or the server side when no instance observed the client, e.g. clients outside of the cluster
+func oneSidePerConnection(observed map[string]model.ConnectionItem) map[string]model.ConnectionItem {
+	var connectionItems = make(map[string]model.ConnectionItem)
+	for _, element := range observed {
+		var key = element.SrcId + "-" + element.DstId
+		if current, ok := connectionItems[key]; !ok || (element.ClientSide && !current.ClientSide) {
+			connectionItems[key] = element
+		}
+	}
+	return connectionItems
+}
+
+// external IPs contacted by workloads from namespaces matching the namespace query param (regexp), e.g. for firewalls
+func (service *Service) buildExternalIPSet(r *http.Request) model.IPSet {
+	var connections []model.ConnectionItem
+	for _, conn := range service.fetchConnections(r) {
+		connections = append(connections, conn)
+	}
+	return externalIPSet(connections, regexp.MustCompile(r.URL.Query().Get("namespace")))
+}
+
+func externalIPSet(connections []model.ConnectionItem, patternNs *regexp.Regexp) model.IPSet {
+	var elements = []string{}
+	for _, conn := range connections {
+		// addresses unknown for k8s resources keep the IP as the workload identifier
+		if !ebpf_tools.IsExternalIP(conn.DstId) || !patternNs.MatchString(conn.SrcNamespace) {
+			continue
+		}
+		if !slices.Contains(elements, conn.DstId) {
+			elements = append(elements, conn.DstId)
+		}
+	}
+	slices.Sort(elements)
+	return model.IPSet{Name: "external", Elements: elements}
+}
+
+// keep the nftables set K8S_PACKET_NFTABLES_TABLE/K8S_PACKET_NFTABLES_SET in sync with external IPs
+// contacted by namespaces matching K8S_PACKET_NFTABLES_NAMESPACE, disabled when refresh period is not set,
+// only connections observed on the local node are used, they cover egress of pods running on it
+func (service *Service) syncNftables() {
+	var refreshPeriod, _ = time.ParseDuration(os.Getenv("K8S_PACKET_NFTABLES_REFRESH_PERIOD"))
+	if refreshPeriod <= 0 {
+		return
+	}
+
+	var table = os.Getenv("K8S_PACKET_NFTABLES_TABLE")
+	if strings.TrimSpace(table) == "" {
+		table = "inet k8spacket"
+	}
+	var set = os.Getenv("K8S_PACKET_NFTABLES_SET")
+	if strings.TrimSpace(set) == "" {
+		set = "external_ips"
+	}
+
+	var all = regexp.MustCompile("")
+	var patternNs = regexp.MustCompile(os.Getenv("K8S_PACKET_NFTABLES_NAMESPACE"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("[nftables] Receive signal, exiting...")
+			return
+		case <-time.After(refreshPeriod):
+			var connections = service.repo.Query(ctx, time.Time{}, time.Time{}, all, all, all, false)
+			ipSet := externalIPSet(connections, patternNs)
+
+			security.UseFeature(security.FeatureExec)
+			cmd := exec.Command("nft", "-f", "-")
+			cmd.Stdin = strings.NewReader(nftablesScript(table, set, ipSet.Elements))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				slog.Error("[nftables] Cannot update set", "set", set, "Error", err, "Output", string(out))
+			}
+		}
+	}
+}
+
+// nft -f applies the whole script as a single transaction, so the set is never observed empty while refreshing
+func nftablesScript(table string, set string, ips []string) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, "add table %s\n", table)
+	fmt.Fprintf(&script, "add set %s %s { type ipv4_addr; }\n", table, set)
+	fmt.Fprintf(&script, "flush set %s %s\n", table, set)
+
+	var ipv4 []string
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			ipv4 = append(ipv4, ip)
+		}
+	}
+	if len(ipv4) > 0 {
+		fmt.Fprintf(&script, "add element %s %s { %s }\n", table, set, strings.Join(ipv4, ", "))
+	}
+	return script.String()
+}
+
+// the same workloads can be observed by k8spacket instances on many nodes, records observed on the same side are summed,
+// the most recently seen pod pair is kept
+func mergeConnections(current model.ConnectionItem, element model.ConnectionItem) model.ConnectionItem {
+	if (model.ConnectionItem{} == current) {
+		return element
+	}
+	if element.LastSeen.Before(current.LastSeen) {
+		element.Src, element.SrcName, element.SrcNamespace = current.Src, current.SrcName, current.SrcNamespace
+		element.Dst, element.DstName, element.DstNamespace = current.Dst, current.DstName, current.DstNamespace
+		element.LastSeen = current.LastSeen
+	}
+	if !current.FirstSeen.IsZero() && (element.FirstSeen.IsZero() || current.FirstSeen.Before(element.FirstSeen)) {
+		element.FirstSeen = current.FirstSeen
+	}
+	element.ConnCount += current.ConnCount
+	element.ConnPersistent += current.ConnPersistent
+	element.BytesSent += current.BytesSent
+	element.BytesReceived += current.BytesReceived
+	element.Duration += current.Duration
+	if current.MaxDuration > element.MaxDuration {
+		element.MaxDuration = current.MaxDuration
+	}
+	return element
+}
+
+// find pods of well-known ingress controllers by their labels, selectors are separated by semicolon
+func (service *Service) getIngressIPs() []string {
+	var refreshPeriod, err = time.ParseDuration(os.Getenv("K8S_PACKET_INGRESS_REFRESH_PERIOD"))
+	if err != nil {
+		refreshPeriod = time.Minute
+	}
+
+	ingressIPs.Lock()
+	defer ingressIPs.Unlock()
+
+	if !ingressIPs.fetchedAt.IsZero() && time.Since(ingressIPs.fetchedAt) < refreshPeriod {
+		return ingressIPs.ips
+	}
+
+	var selectors = os.Getenv("K8S_PACKET_INGRESS_LABEL_SELECTORS")
+	if strings.TrimSpace(selectors) == "" {
+		selectors = defaultIngressLabelSelectors
+	}
+
+	var ips []string
+	for _, selector := range strings.Split(selectors, ";") {
+		if strings.TrimSpace(selector) == "" {
+			continue
+		}
+		selectorIps, err := service.k8sClient.GetPodIPsBySelectors("", strings.TrimSpace(selector))
+		if err != nil {
+			// keep previously found ingress pods and try again with the next request
+			slog.Error("[api] Cannot get ingress pods", "selector", selector, "Error", err)
+			return ingressIPs.ips
+		}
+		ips = append(ips, selectorIps...)
+	}
+	ingressIPs.ips = ips
+	ingressIPs.fetchedAt = time.Now()
+	return ips
+}
+
+// collapse external client -> ingress -> backend into a single logical edge external -> backend annotated with the ingress hop,
+// ingress controllers reached only by in-cluster clients are left as they are
+func collapseIngress(connectionItems map[string]model.ConnectionItem, ingressIds []string) map[string]model.ConnectionItem {
+	var collapsed = make(map[string]model.ConnectionItem)
+
+	// external clients are not k8s resources, so they are identified by their IP
+	var externalIngress = make(map[string]bool)
+	for _, conn := range connectionItems {
+		if slices.Contains(ingressIds, conn.DstId) && ebpf_tools.IsExternalIP(conn.SrcId) {
+			externalIngress[conn.DstId] = true
+		}
+	}
+
+	for key, conn := range connectionItems {
+		if slices.Contains(ingressIds, conn.DstId) && ebpf_tools.IsExternalIP(conn.SrcId) {
+			continue
+		}
+		if externalIngress[conn.SrcId] {
+			conn.Ingress = conn.SrcName
+			conn.SrcId = "external:" + conn.SrcId
+			conn.Src = "external:" + conn.Src
+			conn.SrcName = "external via " + conn.Ingress
+			conn.SrcNamespace = ""
+			key = conn.SrcId + "-" + conn.DstId
+		}
+		collapsed[key] = conn
+	}
+	return collapsed
+}
+
+// connections are keyed by workload identifiers, pods of an ingress controller get new IPs when they are recreated
+func ingressWorkloadIds(ips []string) []string {
+	var ids []string
+	for _, ip := range ips {
+		if id := ebpf_tools.WorkloadId(ip); !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 func (service *Service) getO11yStatsConfig(statsType string) (string, error) {
 	jsonFile, err := service.handlerIO.ReadFile("fields.json")
 	if err != nil {
@@ -137,18 +451,26 @@ func (service *Service) getO11yStatsConfig(statsType string) (string, error) {
 	return response, nil
 }
 
+// connections are counted on the destination endpoint, and apart as outbound ones on the source endpoint,
+// so clients have their reuse stats as well as servers
 func prepareConnections(connectionItems map[string]model.ConnectionItem, connectionEndpoints map[string]model.ConnectionEndpoint) {
 
 	for _, conn := range connectionItems {
-		var connEndpointSrc = connectionEndpoints[conn.Src]
+		var connEndpointSrc = connectionEndpoints[conn.SrcId]
 		if (model.ConnectionEndpoint{} == connEndpointSrc) {
 			connEndpointSrc = *&model.ConnectionEndpoint{Ip: conn.Src, Name: conn.SrcName, Namespace: conn.SrcNamespace, ConnCount: 0, ConnPersistent: 0, BytesSent: 0, BytesReceived: 0, Duration: 0, MaxDuration: 0}
 		}
 		connEndpointSrc.BytesSent += conn.BytesSent
 		connEndpointSrc.BytesReceived += conn.BytesReceived
-		connectionEndpoints[conn.Src] = connEndpointSrc
+		// outbound connections are kept apart from the inbound ones, a connection to itself is counted once, as inbound
+		if conn.SrcId != conn.DstId {
+			connEndpointSrc.ClientConnCount += conn.ConnCount
+			connEndpointSrc.ClientDuration += conn.Duration
+		}
+		seenBetween(&connEndpointSrc, conn)
+		connectionEndpoints[conn.SrcId] = connEndpointSrc
 
-		var connEndpointDst = connectionEndpoints[conn.Dst]
+		var connEndpointDst = connectionEndpoints[conn.DstId]
 		if (model.ConnectionEndpoint{} == connEndpointDst) {
 			connEndpointDst = *&model.ConnectionEndpoint{Ip: conn.Dst, Name: conn.DstName, Namespace: conn.DstNamespace, ConnCount: 0, ConnPersistent: 0, BytesSent: 0, BytesReceived: 0, Duration: 0, MaxDuration: 0}
 		}
@@ -160,7 +482,18 @@ func prepareConnections(connectionItems map[string]model.ConnectionItem, connect
 		if conn.MaxDuration > connEndpointDst.MaxDuration {
 			connEndpointDst.MaxDuration = conn.MaxDuration
 		}
-		connectionEndpoints[conn.Dst] = connEndpointDst
+		seenBetween(&connEndpointDst, conn)
+		connectionEndpoints[conn.DstId] = connEndpointDst
+	}
+}
+
+// the endpoint is seen from the first to the last seen connection
+func seenBetween(endpoint *model.ConnectionEndpoint, conn model.ConnectionItem) {
+	if !conn.FirstSeen.IsZero() && (endpoint.FirstSeen.IsZero() || conn.FirstSeen.Before(endpoint.FirstSeen)) {
+		endpoint.FirstSeen = conn.FirstSeen
+	}
+	if conn.LastSeen.After(endpoint.LastSeen) {
+		endpoint.LastSeen = conn.LastSeen
 	}
 }
 
@@ -169,10 +502,10 @@ func buildApiResponse(connectionItems map[string]model.ConnectionItem, connectio
 	var nodeArray []model.Node
 	var edgeArray []model.Edge
 
-	for _, conn := range connectionItems {
-		nodeArray = fillNodesArray(conn.Src, nodeArray, connectionEndpoints, statsImpl)
-		nodeArray = fillNodesArray(conn.Dst, nodeArray, connectionEndpoints, statsImpl)
-		edgeArray = fillEdgesArray(conn.Src+"-"+conn.Dst, edgeArray, connectionItems, statsImpl)
+	for key, conn := range connectionItems {
+		nodeArray = fillNodesArray(conn.SrcId, nodeArray, connectionEndpoints, statsImpl)
+		nodeArray = fillNodesArray(conn.DstId, nodeArray, connectionEndpoints, statsImpl)
+		edgeArray = fillEdgesArray(key, edgeArray, connectionItems, statsImpl)
 	}
 
 	return model.NodeGraph{Nodes: nodeArray, Edges: edgeArray}
@@ -193,8 +526,9 @@ func fillEdgesArray(id string, edgeArray []model.Edge, connectionItems map[strin
 	var connItem = connectionItems[id]
 	var edge = model.Edge{}
 	edge.Id = id
-	edge.Source = connItem.Src
-	edge.Target = connItem.Dst
+	edge.Source = connItem.SrcId
+	edge.Target = connItem.DstId
+	edge.DetailIngress = connItem.Ingress
 	statsImpl.FillEdgeStats(&edge, connItem)
 	edgeArray = append(edgeArray, edge)
 	return edgeArray
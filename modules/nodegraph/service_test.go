@@ -2,6 +2,7 @@ package nodegraph
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,9 +14,12 @@ import (
 	"testing"
 	"time"
 
+	ebpf_tools "github.com/k8spacket/k8spacket/ebpf/tools"
+	"github.com/k8spacket/k8spacket/external/db"
 	"github.com/k8spacket/k8spacket/external/handlerio"
 	httpclient "github.com/k8spacket/k8spacket/external/http"
 	k8sclient "github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/repository"
 	"github.com/k8spacket/k8spacket/modules/nodegraph/stats"
@@ -29,28 +33,46 @@ var dbState = []model.ConnectionItem{
 }
 
 type mockRepository struct {
-	repo   repository.IRepository[model.ConnectionItem]
-	result model.ConnectionItem
+	repo    repository.IRepository[model.ConnectionItem]
+	result  model.ConnectionItem
+	items   []model.ConnectionItem
+	updated map[string]model.ConnectionItem
+	deleted []string
 }
 
-func (mock *mockRepository) Query(from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp) []model.ConnectionItem {
+func (mock *mockRepository) Query(ctx context.Context, from time.Time, to time.Time, patternNs *regexp.Regexp, patternIn *regexp.Regexp, patternEx *regexp.Regexp, showDeleted bool) []model.ConnectionItem {
+	if mock.items != nil {
+		return mock.items
+	}
 	return dbState
 }
 
 func (mock *mockRepository) Read(key string) model.ConnectionItem {
+	for _, item := range mock.items {
+		if connectionId(item.SrcId, item.DstId, item.ClientSide) == key {
+			return item
+		}
+	}
 	return mock.result
 }
 
 func (mock *mockRepository) Set(key string, value *model.ConnectionItem) {
 	mock.result = *value
+	if mock.updated != nil {
+		mock.updated[key] = *value
+	}
+}
+
+func (mock *mockRepository) Delete(key string) {
+	mock.deleted = append(mock.deleted, key)
 }
 
 type mockK8SClient struct {
 	k8sClient k8sclient.IK8SClient
 }
 
-func (k8sClient *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) []string {
-	return []string{"127.0.0.1"}
+func (k8sClient *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
+	return []string{"127.0.0.1"}, nil
 }
 
 type mockHttpClient struct {
@@ -82,6 +104,29 @@ func (httpClient *mockHttpClient) Do(req *http.Request) (*http.Response, error)
 			StatusCode: http.StatusOK,
 		}, nil
 	}
+	if req.URL.Query().Get("scenario") == "ipset" {
+		result, _ := json.Marshal([]model.ConnectionItem{
+			{SrcId: "app", SrcNamespace: "shop", DstId: "8.8.8.8", Dst: "8.8.8.8"},
+			{SrcId: "db", SrcNamespace: "shop", DstId: "1.1.1.1", Dst: "1.1.1.1"},
+			{SrcId: "web", SrcNamespace: "blog", DstId: "9.9.9.9", Dst: "9.9.9.9"},
+			{SrcId: "web", SrcNamespace: "blog", DstId: "192.168.1.10", Dst: "192.168.1.10"},
+			{SrcId: "web", SrcNamespace: "blog", DstId: "169.254.169.254", Dst: "169.254.169.254"},
+			{SrcId: "app", SrcNamespace: "shop", DstId: "db", Dst: "10.0.0.5", DstNamespace: "shop"},
+			{Src: "10.0.0.7", SrcNamespace: "shop", Dst: "4.4.4.4"},
+		})
+		return &http.Response{
+			Body:       io.NopCloser(bytes.NewBuffer(result)),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+	if req.URL.Query().Get("scenario") == "partial" {
+		result, _ := json.Marshal(dbState)
+		return &http.Response{
+			Header:     http.Header{db.PartialResultHeader: {"true"}},
+			Body:       io.NopCloser(bytes.NewBuffer(result)),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
 	if req.URL.Query().Get("scenario") == "parse" {
 		result := []byte("parse error")
 		return &http.Response{
@@ -89,7 +134,7 @@ func (httpClient *mockHttpClient) Do(req *http.Request) (*http.Response, error)
 			StatusCode: http.StatusOK,
 		}, nil
 	}
-	return &http.Response{}, nil
+	return &http.Response{Body: http.NoBody}, nil
 }
 
 type BrokenReader struct{}
@@ -143,10 +188,10 @@ func TestGetConnections(t *testing.T) {
 	patternIn := regexp.MustCompile("in")
 	patternEx := regexp.MustCompile("ex")
 
-	result := service.getConnections(from, to, patternNs, patternIn, patternEx)
+	result := service.getConnections(context.Background(), from, to, patternNs, patternIn, patternEx, false)
 
 	assert.EqualValues(t, dbState, result)
-	assert.Contains(t, str.String(), fmt.Sprintf("[api:params] patternNs=%s patternIn=%s patternEx=%s from=\"%s\" to=\"%s\"\n",
+	assert.Contains(t, str.String(), fmt.Sprintf("[api:params] patternNs=%s patternIn=%s patternEx=%s from=\"%s\" to=\"%s\" showDeleted=false\n",
 		patternNs, patternIn, patternEx, from.Format(time.DateTime), to.Format(time.DateTime)))
 
 }
@@ -156,10 +201,10 @@ func TestUpdate(t *testing.T) {
 		item model.ConnectionItem
 		want model.ConnectionItem
 	}{
-		{model.ConnectionItem{Src: "src", Dst: "dst", ConnCount: 10, ConnPersistent: 5, BytesReceived: 1000, BytesSent: 500, Duration: 0.5, MaxDuration: 0.5},
-			model.ConnectionItem{Src: "src", SrcName: "srcName", SrcNamespace: "srcNs", Dst: "dst", DstName: "dstName", DstNamespace: "dstNs", ConnCount: 11, ConnPersistent: 6, BytesSent: 600, BytesReceived: 1200, Duration: 1.5, MaxDuration: 1}},
+		{model.ConnectionItem{SrcId: "srcId", Src: "oldSrc", DstId: "dstId", Dst: "dst", DeletedAt: time.Now(), ConnCount: 10, ConnPersistent: 5, BytesReceived: 1000, BytesSent: 500, Duration: 0.5, MaxDuration: 0.5, ClientSide: true},
+			model.ConnectionItem{SrcId: "srcId", Src: "src", SrcName: "srcName", SrcNamespace: "srcNs", DstId: "dstId", Dst: "dst", DstName: "dstName", DstNamespace: "dstNs", ConnCount: 11, ConnPersistent: 6, BytesSent: 600, BytesReceived: 1200, Duration: 1.5, MaxDuration: 1, ClientSide: true}},
 		{model.ConnectionItem{},
-			model.ConnectionItem{Src: "src", SrcName: "srcName", SrcNamespace: "srcNs", Dst: "dst", DstName: "dstName", DstNamespace: "dstNs", ConnCount: 1, ConnPersistent: 1, BytesSent: 100, BytesReceived: 200, Duration: 1, MaxDuration: 1}},
+			model.ConnectionItem{SrcId: "srcId", Src: "src", SrcName: "srcName", SrcNamespace: "srcNs", DstId: "dstId", Dst: "dst", DstName: "dstName", DstNamespace: "dstNs", ConnCount: 1, ConnPersistent: 1, BytesSent: 100, BytesReceived: 200, Duration: 1, MaxDuration: 1, ClientSide: true}},
 	}
 
 	for _, test := range tests {
@@ -168,10 +213,15 @@ func TestUpdate(t *testing.T) {
 			mockRepository := &mockRepository{result: test.item}
 			service := &Service{mockRepository, &stats.Factory{}, &httpclient.HttpClient{}, &k8sclient.K8SClient{}, &handlerio.HandlerIO{}}
 
-			service.update("src", "srcName", "srcNs", "dst", "dstName", "dstNs", true, 100, 200, 1)
+			service.update(modules.Address{Addr: "src", Name: "srcName", Namespace: "srcNs", WorkloadId: "srcId"},
+				modules.Address{Addr: "dst", Name: "dstName", Namespace: "dstNs", WorkloadId: "dstId"}, true, true, 1, 100, 200, 1, 1)
 
 			result := mockRepository.Read("")
 
+			if (model.ConnectionItem{} == test.item) {
+				assert.False(t, result.FirstSeen.IsZero())
+			}
+			test.want.FirstSeen = result.FirstSeen
 			test.want.LastSeen = result.LastSeen
 			assert.EqualValues(t, test.want, result)
 		})
@@ -251,7 +301,8 @@ func TestGetO11yStatsConfig(t *testing.T) {
 				Field{FieldName: "source", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "target", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "mainStat", Type: "string", Color: "", DisplayName: ""},
-				Field{FieldName: "secondaryStat", Type: "string", Color: "", DisplayName: ""}},
+				Field{FieldName: "secondaryStat", Type: "string", Color: "", DisplayName: ""},
+				Field{FieldName: "detail__ingress", Type: "string", Color: "", DisplayName: "Ingress"}},
 			NodesFields: []Field{
 				Field{FieldName: "id", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "title", Type: "string", Color: "", DisplayName: ""},
@@ -266,7 +317,8 @@ func TestGetO11yStatsConfig(t *testing.T) {
 				Field{FieldName: "source", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "target", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "mainStat", Type: "string", Color: "", DisplayName: ""},
-				Field{FieldName: "secondaryStat", Type: "string", Color: "", DisplayName: ""}},
+				Field{FieldName: "secondaryStat", Type: "string", Color: "", DisplayName: ""},
+				Field{FieldName: "detail__ingress", Type: "string", Color: "", DisplayName: "Ingress"}},
 			NodesFields: []Field{
 				Field{FieldName: "id", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "title", Type: "string", Color: "", DisplayName: ""},
@@ -281,7 +333,8 @@ func TestGetO11yStatsConfig(t *testing.T) {
 				Field{FieldName: "source", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "target", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "mainStat", Type: "string", Color: "", DisplayName: ""},
-				Field{FieldName: "secondaryStat", Type: "string", Color: "", DisplayName: ""}},
+				Field{FieldName: "secondaryStat", Type: "string", Color: "", DisplayName: ""},
+				Field{FieldName: "detail__ingress", Type: "string", Color: "", DisplayName: "Ingress"}},
 			NodesFields: []Field{
 				Field{FieldName: "id", Type: "string", Color: "", DisplayName: ""},
 				Field{FieldName: "title", Type: "string", Color: "", DisplayName: ""},
@@ -312,3 +365,219 @@ func TestGetO11yStatsConfig(t *testing.T) {
 	}
 
 }
+
+func TestCollapseIngress(t *testing.T) {
+
+	connectionItems := map[string]model.ConnectionItem{
+		"1.2.3.4-ingress":  {SrcId: "1.2.3.4", Src: "1.2.3.4", SrcName: "Google LLC", DstId: "ingress", Dst: "10.0.0.1", DstName: "pod.ingress-nginx", DstNamespace: "ingress", ConnCount: 5},
+		"client-ingress":   {SrcId: "client", Src: "10.0.0.2", SrcName: "pod.client", SrcNamespace: "app", DstId: "ingress", Dst: "10.0.0.1", DstName: "pod.ingress-nginx", DstNamespace: "ingress", ConnCount: 2},
+		"10.0.0.9-ingress": {SrcId: "10.0.0.9", Src: "10.0.0.9", SrcName: "N/A", DstId: "ingress", Dst: "10.0.0.1", DstName: "pod.ingress-nginx", DstNamespace: "ingress", ConnCount: 6},
+		"ingress-backend":  {SrcId: "ingress", Src: "10.0.0.1", SrcName: "pod.ingress-nginx", SrcNamespace: "ingress", DstId: "backend", Dst: "10.0.0.3", DstName: "pod.backend", DstNamespace: "app", ConnCount: 7},
+		"client-backend":   {SrcId: "client", Src: "10.0.0.2", SrcName: "pod.client", SrcNamespace: "app", DstId: "backend", Dst: "10.0.0.3", DstName: "pod.backend", DstNamespace: "app", ConnCount: 1},
+		"client-internal":  {SrcId: "client", Src: "10.0.0.2", SrcName: "pod.client", SrcNamespace: "app", DstId: "internal", Dst: "10.0.0.4", DstName: "pod.internal-gw", DstNamespace: "ingress", ConnCount: 3},
+		"internal-backend": {SrcId: "internal", Src: "10.0.0.4", SrcName: "pod.internal-gw", SrcNamespace: "ingress", DstId: "backend", Dst: "10.0.0.3", DstName: "pod.backend", DstNamespace: "app", ConnCount: 4},
+	}
+
+	// ingress pods are matched by their workload, the IPs of the connections are the last seen ones
+	result := collapseIngress(connectionItems, []string{"ingress", "internal"})
+
+	assert.EqualValues(t, map[string]model.ConnectionItem{
+		"client-ingress":           {SrcId: "client", Src: "10.0.0.2", SrcName: "pod.client", SrcNamespace: "app", DstId: "ingress", Dst: "10.0.0.1", DstName: "pod.ingress-nginx", DstNamespace: "ingress", ConnCount: 2},
+		"10.0.0.9-ingress":         {SrcId: "10.0.0.9", Src: "10.0.0.9", SrcName: "N/A", DstId: "ingress", Dst: "10.0.0.1", DstName: "pod.ingress-nginx", DstNamespace: "ingress", ConnCount: 6},
+		"external:ingress-backend": {SrcId: "external:ingress", Src: "external:10.0.0.1", SrcName: "external via pod.ingress-nginx", DstId: "backend", Dst: "10.0.0.3", DstName: "pod.backend", DstNamespace: "app", ConnCount: 7, Ingress: "pod.ingress-nginx"},
+		"client-backend":           {SrcId: "client", Src: "10.0.0.2", SrcName: "pod.client", SrcNamespace: "app", DstId: "backend", Dst: "10.0.0.3", DstName: "pod.backend", DstNamespace: "app", ConnCount: 1},
+		"client-internal":          {SrcId: "client", Src: "10.0.0.2", SrcName: "pod.client", SrcNamespace: "app", DstId: "internal", Dst: "10.0.0.4", DstName: "pod.internal-gw", DstNamespace: "ingress", ConnCount: 3},
+		"internal-backend":         {SrcId: "internal", Src: "10.0.0.4", SrcName: "pod.internal-gw", SrcNamespace: "ingress", DstId: "backend", Dst: "10.0.0.3", DstName: "pod.backend", DstNamespace: "app", ConnCount: 4},
+	}, result)
+}
+
+func TestIngressWorkloadIds(t *testing.T) {
+
+	defer ebpf_tools.SetK8sInfo(ebpf_tools.K8sInfo())
+	ebpf_tools.SetK8sInfo(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.1": {Name: "pod.ingress-nginx-1", Namespace: "ingress", WorkloadId: "ingress"},
+		"10.0.0.2": {Name: "pod.ingress-nginx-2", Namespace: "ingress", WorkloadId: "ingress"},
+	})
+
+	assert.EqualValues(t, []string{"ingress", "10.0.0.3"}, ingressWorkloadIds([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+}
+
+func TestGetIngressIPs(t *testing.T) {
+
+	var tests = []struct {
+		selectors string
+		want      int
+	}{
+		{"", 5},
+		{"app=ingress;app=gateway", 2},
+		{"app=ingress; ;", 1},
+	}
+
+	service := &Service{&mockRepository{}, &stats.Factory{}, &mockHttpClient{}, &mockK8SClient{}, &handlerio.HandlerIO{}}
+
+	for _, test := range tests {
+		t.Run(test.selectors, func(t *testing.T) {
+			t.Setenv("K8S_PACKET_INGRESS_LABEL_SELECTORS", test.selectors)
+			ingressIPs.fetchedAt = time.Time{}
+
+			assert.Len(t, service.getIngressIPs(), test.want)
+		})
+	}
+
+	// cached until the refresh period is exceeded
+	t.Setenv("K8S_PACKET_INGRESS_LABEL_SELECTORS", "")
+	assert.Len(t, service.getIngressIPs(), 1)
+	t.Setenv("K8S_PACKET_INGRESS_REFRESH_PERIOD", "0s")
+	assert.Len(t, service.getIngressIPs(), 5)
+}
+
+func TestMergeConnections(t *testing.T) {
+
+	now := time.Now()
+
+	older := model.ConnectionItem{SrcId: "srcId", Src: "10.0.0.1", SrcName: "pod.src-1", DstId: "dstId", Dst: "10.0.0.2", DstName: "pod.dst-1",
+		ConnCount: 2, ConnPersistent: 1, BytesSent: 100, BytesReceived: 200, Duration: 3, MaxDuration: 2, LastSeen: now.Add(-time.Hour)}
+	newer := model.ConnectionItem{SrcId: "srcId", Src: "10.0.0.3", SrcName: "pod.src-2", DstId: "dstId", Dst: "10.0.0.4", DstName: "pod.dst-2",
+		ConnCount: 3, ConnPersistent: 0, BytesSent: 10, BytesReceived: 20, Duration: 1, MaxDuration: 1, LastSeen: now}
+	want := model.ConnectionItem{SrcId: "srcId", Src: "10.0.0.3", SrcName: "pod.src-2", DstId: "dstId", Dst: "10.0.0.4", DstName: "pod.dst-2",
+		ConnCount: 5, ConnPersistent: 1, BytesSent: 110, BytesReceived: 220, Duration: 4, MaxDuration: 2, LastSeen: now}
+
+	assert.EqualValues(t, older, mergeConnections(model.ConnectionItem{}, older))
+	assert.EqualValues(t, want, mergeConnections(older, newer))
+	assert.EqualValues(t, want, mergeConnections(newer, older))
+
+	// the same pod pair observed again after the previous record was merged, e.g. a record of another day
+	assert.EqualValues(t, model.ConnectionItem{SrcId: "srcId", Src: "10.0.0.3", SrcName: "pod.src-2", DstId: "dstId", Dst: "10.0.0.4", DstName: "pod.dst-2",
+		ConnCount: 8, ConnPersistent: 1, BytesSent: 120, BytesReceived: 240, Duration: 5, MaxDuration: 2, LastSeen: now}, mergeConnections(want, newer))
+}
+
+func TestOneSidePerConnection(t *testing.T) {
+
+	// connection between nodes observed on both of them, connection from an external client observed on the server only
+	clientSide := model.ConnectionItem{SrcId: "srcId", DstId: "dstId", ConnCount: 3, ClientSide: true}
+	serverSide := model.ConnectionItem{SrcId: "srcId", DstId: "dstId", ConnCount: 2}
+	external := model.ConnectionItem{SrcId: "1.1.1.1", DstId: "dstId", ConnCount: 5}
+
+	assert.EqualValues(t, map[string]model.ConnectionItem{"srcId-dstId": clientSide, "1.1.1.1-dstId": external},
+		oneSidePerConnection(map[string]model.ConnectionItem{"srcId-dstId-false": serverSide, "srcId-dstId-true": clientSide, "1.1.1.1-dstId-false": external}))
+}
+
+func TestTombstone(t *testing.T) {
+
+	os.Setenv("K8S_PACKET_TCP_TOMBSTONE_GRACE_PERIOD", "1h")
+	defer os.Unsetenv("K8S_PACKET_TCP_TOMBSTONE_GRACE_PERIOD")
+
+	now := time.Now()
+
+	var items = []model.ConnectionItem{
+		{SrcId: "alive", DstId: "10.0.0.1"},
+		{SrcId: "alive", DstId: "gone"},
+		{SrcId: "alive", DstId: "alive", DeletedAt: now.Add(-time.Minute)},
+		{SrcId: "gone", DstId: "alive", DeletedAt: now.Add(-time.Minute)},
+		{SrcId: "gone", DstId: "gone", DeletedAt: now.Add(-time.Hour * 2)},
+	}
+
+	mockRepository := &mockRepository{items: items, updated: map[string]model.ConnectionItem{}}
+	service := &Service{mockRepository, &stats.Factory{}, &mockHttpClient{}, &mockK8SClient{}, &handlerio.HandlerIO{}}
+
+	service.tombstone(nil, now)
+	assert.Empty(t, mockRepository.updated)
+	assert.Empty(t, mockRepository.deleted)
+
+	service.tombstone(map[string]bool{"alive": true}, now)
+
+	assert.Len(t, mockRepository.updated, 2)
+	assert.EqualValues(t, now, mockRepository.updated[connectionId("alive", "gone", false)].DeletedAt)
+	assert.True(t, mockRepository.updated[connectionId("alive", "alive", false)].DeletedAt.IsZero())
+	assert.EqualValues(t, []string{connectionId("gone", "gone", false)}, mockRepository.deleted)
+}
+
+func TestTombstoneDefaultGracePeriod(t *testing.T) {
+
+	now := time.Now()
+
+	var items = []model.ConnectionItem{
+		{SrcId: "gone", DstId: "alive", DeletedAt: now.Add(-time.Hour * 2)},
+		{SrcId: "gone", DstId: "gone", DeletedAt: now.Add(-time.Hour * 25)},
+	}
+
+	mockRepository := &mockRepository{items: items, updated: map[string]model.ConnectionItem{}}
+	service := &Service{mockRepository, &stats.Factory{}, &mockHttpClient{}, &mockK8SClient{}, &handlerio.HandlerIO{}}
+
+	service.tombstone(map[string]bool{"alive": true}, now)
+
+	assert.Empty(t, mockRepository.updated)
+	assert.EqualValues(t, []string{connectionId("gone", "gone", false)}, mockRepository.deleted)
+}
+
+func TestWorkloadIds(t *testing.T) {
+
+	assert.Nil(t, workloadIds(map[string]k8sclient.IPResourceInfo{}))
+	assert.EqualValues(t, map[string]bool{"123": true, "456": true}, workloadIds(map[string]k8sclient.IPResourceInfo{
+		"10.0.0.1": {Name: "pod.backend-1", WorkloadId: "123"},
+		"10.0.0.2": {Name: "pod.backend-2", WorkloadId: "123"},
+		"10.0.0.3": {Name: "svc.backend", WorkloadId: "456"},
+	}))
+}
+
+func TestBuildExternalIPSet(t *testing.T) {
+
+	var tests = []struct {
+		namespace string
+		want      []string
+	}{
+		{"", []string{"1.1.1.1", "4.4.4.4", "8.8.8.8", "9.9.9.9"}},
+		{"^shop$", []string{"1.1.1.1", "4.4.4.4", "8.8.8.8"}},
+		{"^none$", []string{}},
+	}
+
+	service := &Service{&mockRepository{}, &stats.Factory{}, &mockHttpClient{}, &mockK8SClient{}, &handlerio.HandlerIO{}}
+
+	for _, test := range tests {
+		t.Run(test.namespace, func(t *testing.T) {
+
+			r, _ := http.NewRequest(http.MethodGet, "", nil)
+
+			q := r.URL.Query()
+			q.Set("scenario", "ipset")
+			q.Set("namespace", test.namespace)
+			r.URL.RawQuery = q.Encode()
+
+			result := service.buildExternalIPSet(r)
+
+			assert.EqualValues(t, model.IPSet{Name: "external", Elements: test.want}, result)
+		})
+	}
+}
+
+func TestFetchConnectionsPartialResult(t *testing.T) {
+
+	service := &Service{&mockRepository{}, &stats.Factory{}, &mockHttpClient{}, &mockK8SClient{}, &handlerio.HandlerIO{}}
+
+	for _, scenario := range []string{"ok", "partial"} {
+		t.Run(scenario, func(t *testing.T) {
+
+			budget := db.NewBudget()
+			r, _ := http.NewRequestWithContext(db.WithBudget(context.Background(), budget), http.MethodGet, "?scenario="+scenario, nil)
+
+			result := service.fetchConnections(r)
+
+			assert.NotEmpty(t, result)
+			assert.EqualValues(t, scenario == "partial", budget.Exceeded())
+		})
+	}
+}
+
+func TestNftablesScript(t *testing.T) {
+
+	assert.EqualValues(t, "add table inet k8spacket\n"+
+		"add set inet k8spacket external_ips { type ipv4_addr; }\n"+
+		"flush set inet k8spacket external_ips\n"+
+		"add element inet k8spacket external_ips { 1.1.1.1, 8.8.8.8 }\n",
+		nftablesScript("inet k8spacket", "external_ips", []string{"1.1.1.1", "2001:db8::1", "8.8.8.8"}))
+
+	assert.EqualValues(t, "add table inet k8spacket\n"+
+		"add set inet k8spacket external_ips { type ipv4_addr; }\n"+
+		"flush set inet k8spacket external_ips\n",
+		nftablesScript("inet k8spacket", "external_ips", []string{}))
+}
@@ -9,6 +9,8 @@ func (factory *Factory) GetStats(statsType string) IStats {
 		return &Bytes{}
 	case "duration":
 		return &Duration{}
+	case "reuse":
+		return &Reuse{}
 	default:
 		return &Connection{}
 	}
@@ -15,6 +15,7 @@ func TestGetStats(t *testing.T) {
 	}{
 		{"bytes", &Bytes{}},
 		{"duration", &Duration{}},
+		{"reuse", &Reuse{}},
 		{"connection", &Connection{}},
 		{"", &Connection{}},
 	}
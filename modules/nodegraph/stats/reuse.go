@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
+)
+
+// connection reuse - clients opening a new connection per request show a high rate of new connections, each of them
+// open only for a small part of the observed time, while reused connections stay open with few new ones
+type Reuse struct {
+	IStats
+}
+
+func (reuse *Reuse) GetConfig() model.Config {
+	return model.Config{Arc1: model.DisplayConfig{DisplayName: "Kept alive", Color: "green"},
+		Arc2:          model.DisplayConfig{DisplayName: "Reconnecting", Color: "red"},
+		MainStat:      model.DisplayConfig{DisplayName: "New connections per minute "},
+		SecondaryStat: model.DisplayConfig{DisplayName: "Bytes per connection "}}
+}
+
+// inbound and outbound connections of the endpoint, so clients have the stats as well as servers
+func (reuse *Reuse) FillNodeStats(node *model.Node, connEndpoint model.ConnectionEndpoint) {
+	var connCount = connEndpoint.ConnCount + connEndpoint.ClientConnCount
+	if rate, keptAlive, ok := churn(connCount, connEndpoint.Duration+connEndpoint.ClientDuration, connEndpoint.FirstSeen, connEndpoint.LastSeen); ok {
+		var bpc = bytesize.New((connEndpoint.BytesSent + connEndpoint.BytesReceived) / float64(connCount))
+		node.MainStat = fmt.Sprintf("new: %.1f/min", rate)
+		node.SecondaryStat = fmt.Sprintf("per conn: %s", bpc)
+		node.Arc1 = keptAlive
+		node.Arc2 = 1 - keptAlive
+	} else {
+		node.MainStat = fmt.Sprint("new: N/A")
+		node.SecondaryStat = fmt.Sprint("per conn: N/A")
+	}
+}
+
+func (reuse *Reuse) FillEdgeStats(edge *model.Edge, connItem model.ConnectionItem) {
+	if rate, _, ok := churn(connItem.ConnCount, connItem.Duration, connItem.FirstSeen, connItem.LastSeen); ok {
+		var bpc = bytesize.New((connItem.BytesSent + connItem.BytesReceived) / float64(connItem.ConnCount))
+		edge.MainStat = fmt.Sprintf("new: %.1f/min", rate)
+		edge.SecondaryStat = fmt.Sprintf("per conn: %s", bpc)
+	} else {
+		edge.MainStat = fmt.Sprint("new: N/A")
+		edge.SecondaryStat = fmt.Sprint("per conn: N/A")
+	}
+}
+
+// new connections per minute between the first and the last seen connection and the share of that time
+// covered by open connections (duration is in milliseconds), unknown for records without the first seen time
+func churn(connCount int64, duration float64, firstSeen time.Time, lastSeen time.Time) (float64, float64, bool) {
+	var window = lastSeen.Sub(firstSeen)
+	if connCount == 0 || firstSeen.IsZero() || window <= 0 {
+		return 0, 0, false
+	}
+	return float64(connCount) / window.Minutes(), math.Min(duration/float64(window.Milliseconds()), 1), true
+}
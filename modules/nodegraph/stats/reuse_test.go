@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k8spacket/k8spacket/modules/nodegraph/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReuseGetConfig(t *testing.T) {
+	want := model.Config{Arc1: model.DisplayConfig{DisplayName: "Kept alive", Color: "green"},
+		Arc2:          model.DisplayConfig{DisplayName: "Reconnecting", Color: "red"},
+		MainStat:      model.DisplayConfig{DisplayName: "New connections per minute "},
+		SecondaryStat: model.DisplayConfig{DisplayName: "Bytes per connection "}}
+
+	reuse := &Reuse{}
+
+	result := reuse.GetConfig()
+
+	assert.EqualValues(t, want, result)
+
+}
+
+func TestReuseFillNodeStats(t *testing.T) {
+
+	var lastSeen = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		connectionEndpoint model.ConnectionEndpoint
+		want               *model.Node
+	}{
+		// a connection per request, 100 connections in 10 minutes open for 6 seconds in total
+		{model.ConnectionEndpoint{ConnCount: 100, Duration: 6000, BytesSent: 300, BytesReceived: 100, FirstSeen: lastSeen.Add(-10 * time.Minute), LastSeen: lastSeen}, &model.Node{MainStat: "new: 10.0/min", SecondaryStat: "per conn: 4.00B", Arc1: 0.01, Arc2: 0.99, Arc3: 0}},
+		// a connection kept alive for the whole time
+		{model.ConnectionEndpoint{ConnCount: 2, Duration: 1200000, FirstSeen: lastSeen.Add(-10 * time.Minute), LastSeen: lastSeen}, &model.Node{MainStat: "new: 0.2/min", SecondaryStat: "per conn: 0.00B", Arc1: 1, Arc2: 0, Arc3: 0}},
+		// a client with outbound connections only, 20 connections in 10 minutes open for 6 seconds in total
+		{model.ConnectionEndpoint{ClientConnCount: 20, ClientDuration: 6000, BytesSent: 30, BytesReceived: 10, FirstSeen: lastSeen.Add(-10 * time.Minute), LastSeen: lastSeen}, &model.Node{MainStat: "new: 2.0/min", SecondaryStat: "per conn: 2.00B", Arc1: 0.01, Arc2: 0.99, Arc3: 0}},
+		{model.ConnectionEndpoint{ConnCount: 4, LastSeen: lastSeen}, &model.Node{MainStat: "new: N/A", SecondaryStat: "per conn: N/A"}},
+		{model.ConnectionEndpoint{}, &model.Node{MainStat: "new: N/A", SecondaryStat: "per conn: N/A"}},
+	}
+
+	reuse := &Reuse{}
+
+	for _, test := range tests {
+		t.Run(test.want.Id, func(t *testing.T) {
+			t.Parallel()
+
+			node := &model.Node{}
+			reuse.FillNodeStats(node, test.connectionEndpoint)
+
+			assert.EqualValues(t, test.want, node)
+		},
+		)
+	}
+}
+
+func TestReuseFillEdgeStats(t *testing.T) {
+
+	var lastSeen = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		ConnectionItem model.ConnectionItem
+		want           *model.Edge
+	}{
+		{model.ConnectionItem{ConnCount: 8, BytesSent: 3072, BytesReceived: 5120, FirstSeen: lastSeen.Add(-2 * time.Minute), LastSeen: lastSeen}, &model.Edge{MainStat: "new: 4.0/min", SecondaryStat: "per conn: 1.00KB"}},
+		{model.ConnectionItem{ConnCount: 8, LastSeen: lastSeen}, &model.Edge{MainStat: "new: N/A", SecondaryStat: "per conn: N/A"}},
+		{model.ConnectionItem{}, &model.Edge{MainStat: "new: N/A", SecondaryStat: "per conn: N/A"}},
+	}
+
+	reuse := &Reuse{}
+
+	for _, test := range tests {
+		t.Run(test.want.Id, func(t *testing.T) {
+			t.Parallel()
+
+			edge := &model.Edge{}
+			reuse.FillEdgeStats(edge, test.ConnectionItem)
+
+			assert.EqualValues(t, test.want, edge)
+		},
+		)
+	}
+}
@@ -0,0 +1,37 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/k8spacket/k8spacket/external/db"
+	"github.com/k8spacket/k8spacket/modules/telemetry/model"
+)
+
+type Controller struct {
+	service IService
+}
+
+// raw counters of this agent, queried by other k8spacket instances only to build the cluster report
+func (controller *Controller) CountersHandler(w http.ResponseWriter, r *http.Request) {
+	prepareResponse(w, controller.service.buildCounters())
+}
+
+func (controller *Controller) ClusterReportHandler(w http.ResponseWriter, r *http.Request) {
+	budget := db.NewBudget()
+	out := controller.service.buildClusterReport(db.WithBudget(r.Context(), budget), fmt.Sprintf("http://%%s:%s/telemetry/counters", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT")))
+	budget.SetHeader(w.Header())
+	prepareResponse(w, out)
+}
+
+func prepareResponse(w http.ResponseWriter, out model.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(out)
+	if err != nil {
+		slog.Error("[api] Cannot prepare telemetry response", "Error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
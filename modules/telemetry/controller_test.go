@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountersHandler(t *testing.T) {
+
+	service := &Service{httpClient: &mockHttpClient{}, k8sClient: &mockK8SClient{ips: []string{"agent-1"}}, report: newReport()}
+	controller := &Controller{service}
+
+	var tests = []struct {
+		scenario string
+		handler  http.HandlerFunc
+		body     string
+	}{
+		{"agent", controller.CountersHandler, "\"agents\":1,\"tcpConnections\":0"},
+		{"cluster", controller.ClusterReportHandler, "\"agents\":1,\"tcpConnections\":10"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			rr := httptest.NewRecorder()
+
+			test.handler(rr, req)
+
+			assert.EqualValues(t, http.StatusOK, rr.Code)
+			assert.EqualValues(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Body.String(), test.body)
+		})
+	}
+}
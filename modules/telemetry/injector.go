@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+)
+
+// telemetry is aggregated in memory of the agent only and is never sent anywhere by k8spacket itself
+func Init(mux *http.ServeMux) (modules.IListener[modules.TCPEvent], modules.IListener[modules.TLSEvent]) {
+
+	enabled, _ := strconv.ParseBool(os.Getenv("K8S_PACKET_TELEMETRY_ENABLED"))
+	if !enabled {
+		return modules.Listeners[modules.TCPEvent]{}, modules.Listeners[modules.TLSEvent]{}
+	}
+
+	service := &Service{httpClient: &httpclient.HttpClient{}, k8sClient: &k8sclient.K8SClient{}, report: newReport()}
+	controller := &Controller{service}
+
+	mux.HandleFunc("/telemetry/counters", controller.CountersHandler)
+	mux.HandleFunc("/telemetry/api/report", controller.ClusterReportHandler)
+
+	return &TCPListener{service}, &TLSListener{service}
+
+}
@@ -0,0 +1,37 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit(t *testing.T) {
+
+	mux := http.NewServeMux()
+	tcpListener, tlsListener := Init(mux)
+
+	assert.IsType(t, modules.Listeners[modules.TCPEvent]{}, tcpListener)
+	assert.IsType(t, modules.Listeners[modules.TLSEvent]{}, tlsListener)
+
+	os.Setenv("K8S_PACKET_TELEMETRY_ENABLED", "true")
+	defer os.Unsetenv("K8S_PACKET_TELEMETRY_ENABLED")
+
+	mux = http.NewServeMux()
+	tcpListener, tlsListener = Init(mux)
+
+	tcpListener.Listen(modules.TCPEvent{TxB: 5, Count: 1})
+	tlsListener.Listen(modules.TLSEvent{UsedTlsVersion: 0x0304})
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/telemetry/counters", nil)
+	mux.ServeHTTP(rr, req)
+
+	assert.Contains(t, rr.Body.String(), "\"tcpConnections\":1")
+	assert.Contains(t, rr.Body.String(), "\"bytesSent\":5")
+	assert.Contains(t, rr.Body.String(), "\"tlsVersions\":{\"TLS 1.3\":1}")
+}
@@ -0,0 +1,16 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/telemetry/model"
+)
+
+type IService interface {
+	addTCPEvent(event modules.TCPEvent)
+	addTLSEvent(event modules.TLSEvent)
+
+	buildCounters() model.Report
+	buildClusterReport(ctx context.Context, url string) model.Report
+}
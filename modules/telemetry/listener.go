@@ -0,0 +1,21 @@
+package telemetry
+
+import (
+	"github.com/k8spacket/k8spacket/modules"
+)
+
+type TCPListener struct {
+	service IService
+}
+
+type TLSListener struct {
+	service IService
+}
+
+func (listener *TCPListener) Listen(event modules.TCPEvent) {
+	listener.service.addTCPEvent(event)
+}
+
+func (listener *TLSListener) Listen(event modules.TLSEvent) {
+	listener.service.addTLSEvent(event)
+}
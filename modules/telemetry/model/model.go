@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// coarse statistics without any address, name or domain of the observed workloads
+type Report struct {
+	Since                time.Time         `json:"since"`
+	Agents               int               `json:"agents"`
+	TCPConnections       uint64            `json:"tcpConnections"`
+	InClusterConnections uint64            `json:"inClusterConnections"`
+	ExternalConnections  uint64            `json:"externalConnections"`
+	BytesSent            uint64            `json:"bytesSent"`
+	BytesReceived        uint64            `json:"bytesReceived"`
+	TLSConnections       uint64            `json:"tlsConnections"`
+	TLSVersions          map[string]uint64 `json:"tlsVersions"`
+	CipherSuites         map[string]uint64 `json:"cipherSuites"`
+}
@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"context"
+	"maps"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/fanout"
+	httpclient "github.com/k8spacket/k8spacket/external/http"
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/telemetry/model"
+	"github.com/k8spacket/k8spacket/modules/tls-parser/dict"
+)
+
+const otherBucket = "other"
+
+type Service struct {
+	httpClient httpclient.IHttpClient
+	k8sClient  k8sclient.IK8SClient
+	report     model.Report
+	mutex      sync.Mutex
+}
+
+func newReport() model.Report {
+	return model.Report{Since: time.Now(), Agents: 1, TLSVersions: make(map[string]uint64), CipherSuites: make(map[string]uint64)}
+}
+
+// only counters are taken from the event, addresses and names are dropped here
+func (service *Service) addTCPEvent(event modules.TCPEvent) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.report.TCPConnections += event.Count
+	if event.Server.Namespace == "" {
+		service.report.ExternalConnections += event.Count
+	} else {
+		service.report.InClusterConnections += event.Count
+	}
+	service.report.BytesSent += event.TxB
+	service.report.BytesReceived += event.RxB
+}
+
+func (service *Service) addTLSEvent(event modules.TLSEvent) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	service.report.TLSConnections++
+	service.report.TLSVersions[bucketName(dict.ParseTLSVersion(event.UsedTlsVersion))]++
+	service.report.CipherSuites[bucketName(dict.ParseCipherSuite(event.UsedCipher))]++
+}
+
+func bucketName(name string) string {
+	if name == "" {
+		return otherBucket
+	}
+	return name
+}
+
+// raw counters of this agent, rare buckets are suppressed once in the cluster report,
+// as buckets suppressed on every agent could add up to a count worth reporting
+func (service *Service) buildCounters() model.Report {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	report := service.report
+	report.TLSVersions = maps.Clone(report.TLSVersions)
+	report.CipherSuites = maps.Clone(report.CipherSuites)
+	return report
+}
+
+// buckets counted less than K8S_PACKET_TELEMETRY_MIN_COUNT times are merged into "other",
+// so a rarely used version or cipher suite cannot point to a single workload
+func suppressRare(distribution map[string]uint64) map[string]uint64 {
+	minCount, err := strconv.ParseUint(os.Getenv("K8S_PACKET_TELEMETRY_MIN_COUNT"), 10, 64)
+	if err != nil {
+		minCount = 5
+	}
+
+	result := make(map[string]uint64)
+	for name, count := range distribution {
+		if count < minCount {
+			name = otherBucket
+		}
+		result[name] += count
+	}
+	return result
+}
+
+func (service *Service) buildClusterReport(ctx context.Context, url string) model.Report {
+	out := fanout.Get(ctx, service.httpClient, service.k8sClient, url, model.Report{TLSVersions: make(map[string]uint64), CipherSuites: make(map[string]uint64)}, mergeReports)
+
+	out.TLSVersions = suppressRare(out.TLSVersions)
+	out.CipherSuites = suppressRare(out.CipherSuites)
+	return out
+}
+
+func mergeReports(out model.Report, in model.Report) model.Report {
+	if out.Since.IsZero() || in.Since.Before(out.Since) {
+		out.Since = in.Since
+	}
+	out.Agents += in.Agents
+	out.TCPConnections += in.TCPConnections
+	out.InClusterConnections += in.InClusterConnections
+	out.ExternalConnections += in.ExternalConnections
+	out.BytesSent += in.BytesSent
+	out.BytesReceived += in.BytesReceived
+	out.TLSConnections += in.TLSConnections
+	for name, count := range in.TLSVersions {
+		out.TLSVersions[name] += count
+	}
+	for name, count := range in.CipherSuites {
+		out.CipherSuites[name] += count
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/k8spacket/k8spacket/external/k8s"
+	"github.com/k8spacket/k8spacket/modules"
+	"github.com/k8spacket/k8spacket/modules/telemetry/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHttpClient struct {
+}
+
+var agentReport = model.Report{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Agents: 1, TCPConnections: 10, InClusterConnections: 7, ExternalConnections: 3,
+	BytesSent: 100, BytesReceived: 200, TLSConnections: 4,
+	TLSVersions:  map[string]uint64{"TLS 1.3": 3, "TLS 1.2": 1},
+	CipherSuites: map[string]uint64{"TLS_AES_128_GCM_SHA256": 4}}
+
+func (httpClient *mockHttpClient) Do(req *http.Request) (*http.Response, error) {
+	switch req.URL.Hostname() {
+	case "error":
+		return nil, errors.New("error")
+	case "parse":
+		return &http.Response{Body: io.NopCloser(bytes.NewBufferString("{")), StatusCode: http.StatusOK}, nil
+	case "not-found":
+		return &http.Response{Body: io.NopCloser(bytes.NewBuffer(nil)), StatusCode: http.StatusNotFound}, nil
+	}
+	result, _ := json.Marshal(agentReport)
+	return &http.Response{Body: io.NopCloser(bytes.NewBuffer(result)), StatusCode: http.StatusOK}, nil
+}
+
+type mockK8SClient struct {
+	k8sClient k8sclient.IK8SClient
+	ips       []string
+}
+
+func (k8sClient *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
+	return k8sClient.ips, nil
+}
+
+func TestBuildCounters(t *testing.T) {
+
+	service := &Service{report: newReport()}
+
+	service.addTCPEvent(modules.TCPEvent{Client: modules.Address{Namespace: "secret-ns"}, Server: modules.Address{Namespace: "secret-ns"}, TxB: 10, RxB: 20, Count: 1})
+	// summary of 3 connections of a flow aggregated in the kernel
+	service.addTCPEvent(modules.TCPEvent{Client: modules.Address{Namespace: "secret-ns"}, Server: modules.Address{Addr: "1.1.1.1"}, TxB: 1, RxB: 2, Count: 3})
+	for range 5 {
+		service.addTLSEvent(modules.TLSEvent{ServerName: "k8spacket.io", UsedTlsVersion: 0x0304, UsedCipher: 0x1301})
+	}
+	service.addTLSEvent(modules.TLSEvent{ServerName: "k8spacket.io", UsedTlsVersion: 0x0301, UsedCipher: 0x0005})
+	service.addTLSEvent(modules.TLSEvent{ServerName: "k8spacket.io", UsedTlsVersion: 0x9999, UsedCipher: 0x0005})
+
+	report := service.buildCounters()
+
+	assert.EqualValues(t, 4, report.TCPConnections)
+	assert.EqualValues(t, 1, report.InClusterConnections)
+	assert.EqualValues(t, 3, report.ExternalConnections)
+	assert.EqualValues(t, 11, report.BytesSent)
+	assert.EqualValues(t, 22, report.BytesReceived)
+	assert.EqualValues(t, 7, report.TLSConnections)
+	// rare buckets are kept, they are suppressed in the cluster report only
+	assert.EqualValues(t, map[string]uint64{"TLS 1.3": 5, "TLS 1.0": 1, "other": 1}, report.TLSVersions)
+
+	// the counters are a copy, counting goes on
+	service.addTLSEvent(modules.TLSEvent{ServerName: "k8spacket.io", UsedTlsVersion: 0x0304, UsedCipher: 0x1301})
+	assert.EqualValues(t, 5, report.TLSVersions["TLS 1.3"])
+
+	result, _ := json.Marshal(report)
+	assert.NotContains(t, string(result), "k8spacket.io")
+	assert.NotContains(t, string(result), "1.1.1.1")
+	assert.NotContains(t, string(result), "secret-ns")
+}
+
+func TestSuppressRare(t *testing.T) {
+
+	t.Setenv("K8S_PACKET_TELEMETRY_MIN_COUNT", "")
+	assert.EqualValues(t, map[string]uint64{"TLS 1.3": 5, "other": 2}, suppressRare(map[string]uint64{"TLS 1.3": 5, "TLS 1.0": 1, "other": 1}))
+
+	t.Setenv("K8S_PACKET_TELEMETRY_MIN_COUNT", "0")
+	assert.EqualValues(t, map[string]uint64{"TLS 1.3": 5, "TLS 1.0": 1, "other": 1}, suppressRare(map[string]uint64{"TLS 1.3": 5, "TLS 1.0": 1, "other": 1}))
+}
+
+func TestBuildClusterReport(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	// "TLS 1.2" is rare on every agent, but not in the cluster
+	t.Setenv("K8S_PACKET_TELEMETRY_MIN_COUNT", "2")
+
+	service := &Service{httpClient: &mockHttpClient{}, k8sClient: &mockK8SClient{ips: []string{"agent-1", "agent-2", "error", "parse", "not-found"}}}
+
+	report := service.buildClusterReport(context.Background(), "http://%s:6676/telemetry/counters")
+
+	assert.EqualValues(t, agentReport.Since, report.Since)
+	assert.EqualValues(t, 2, report.Agents)
+	assert.EqualValues(t, 20, report.TCPConnections)
+	assert.EqualValues(t, 400, report.BytesReceived)
+	assert.EqualValues(t, map[string]uint64{"TLS 1.3": 6, "TLS 1.2": 2}, report.TLSVersions)
+	assert.EqualValues(t, map[string]uint64{"TLS_AES_128_GCM_SHA256": 8}, report.CipherSuites)
+	assert.Contains(t, str.String(), "[api] Cannot get stats")
+	assert.Contains(t, str.String(), "[api] Cannot parse stats response")
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/k8spacket/k8spacket/external/db"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/model"
 )
 
@@ -14,6 +15,19 @@ type Controller struct {
 	service IService
 }
 
+func (controller *Controller) TLSPostureHandler(w http.ResponseWriter, req *http.Request) {
+	budget := db.NewBudget()
+	posture := controller.service.filterPosture(db.WithBudget(req.Context(), budget), req.URL.Query())
+	budget.SetHeader(w.Header())
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(posture)
+	if err != nil {
+		slog.Error("[api] Cannot prepare posture response", "Error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (controller *Controller) TLSConnectionHandler(w http.ResponseWriter, req *http.Request) {
 	id := strings.TrimPrefix(req.URL.Path, "/tlsparser/connections/")
 	if len(id) > 0 {
@@ -30,8 +44,12 @@ func (controller *Controller) TLSConnectionHandler(w http.ResponseWriter, req *h
 			w.Write([]byte("Not Found 404"))
 		}
 	} else {
+		budget := db.NewBudget()
+		connections := controller.service.filterConnections(db.WithBudget(req.Context(), budget), req.URL.Query())
+		budget.SetHeader(w.Header())
+
 		w.Header().Set("Content-Type", "application/json")
-		err := json.NewEncoder(w).Encode(controller.service.filterConnections(req.URL.Query()))
+		err := json.NewEncoder(w).Encode(connections)
 		if err != nil {
 			slog.Error("[api] Cannot prepare connections response", "Error", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1,12 +1,14 @@
 package tlsparser
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/k8spacket/k8spacket/modules/tls-parser/model"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,14 @@ var repo = []model.TLSConnection{
 
 var repoDetail = model.TLSDetails{Id: "id1", Domain: "k8spacket.io", UsedTLSVersion: "TLS 1.2"}
 
+var snapshots = []model.TLSPostureSnapshot{
+	model.TLSPostureSnapshot{Id: "2024-01-01", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Handshakes: map[string]model.TLSHandshake{"1": {UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_128_GCM_SHA256"}}},
+}
+
+var trend = []model.TLSPosture{
+	model.TLSPosture{Id: "2024-01-01", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Connections: 1, Versions: map[string]int64{"TLS 1.3": 1}, CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 1}},
+}
+
 type mockService struct {
 	IService
 	client, server     string
@@ -42,10 +52,37 @@ func (mockService *mockService) getConnection(id string) model.TLSDetails {
 	return repoDetail
 }
 
-func (mockService *mockService) filterConnections(query url.Values) []model.TLSConnection {
+func (mockService *mockService) filterConnections(ctx context.Context, query url.Values) []model.TLSConnection {
 	return repo
 }
 
+func (mockService *mockService) filterPosture(ctx context.Context, query url.Values) []model.TLSPostureSnapshot {
+	return snapshots
+}
+
+func TestTLSPostureHandler(t *testing.T) {
+
+	service := &mockService{}
+	controller := &Controller{service: service}
+
+	req, err := http.NewRequest("GET", "/tlsparser/posture", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(controller.TLSPostureHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+
+	var response []model.TLSPostureSnapshot
+	json.Unmarshal([]byte(rr.Body.String()), &response)
+
+	assert.EqualValues(t, snapshots, response)
+}
+
 func TestTLSConnectionHandler(t *testing.T) {
 
 	service := &mockService{}
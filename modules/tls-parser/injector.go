@@ -20,15 +20,20 @@ func Init(mux *http.ServeMux) modules.IListener[modules.TLSEvent] {
 
 	handlerConnections, _ := db.New[model.TLSConnection]("tls_connections")
 	handlerDetails, _ := db.New[model.TLSDetails]("tls_details")
-	repo := &repository.Repository{DbConnectionHandler: handlerConnections, DbDetailsHandler: handlerDetails}
+	handlerPosture, _ := db.New[model.TLSPostureSnapshot]("tls_posture")
+	repo := &repository.Repository{DbConnectionHandler: handlerConnections, DbDetailsHandler: handlerDetails, DbPostureHandler: handlerPosture}
 	cert := &certificate.Certificate{Network: &network.Network{}}
 	service := &Service{repo, cert, &httpclient.HttpClient{}, &k8sclient.K8SClient{}}
 	controller := &Controller{service}
 	o11yController := &O11yController{service}
 
 	mux.HandleFunc("/tlsparser/connections/", controller.TLSConnectionHandler)
+	mux.HandleFunc("/tlsparser/posture", controller.TLSPostureHandler)
 	mux.HandleFunc("/tlsparser/api/data", o11yController.TLSParserConnectionsHandler)
 	mux.HandleFunc("/tlsparser/api/data/", o11yController.TLSParserConnectionDetailsHandler)
+	mux.HandleFunc("/tlsparser/api/trend", o11yController.TLSParserTrendHandler)
+
+	go service.snapshotPosture()
 
 	listener := &Listener{service}
 
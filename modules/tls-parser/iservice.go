@@ -1,6 +1,7 @@
 package tlsparser
 
 import (
+	"context"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/model"
 	"net/url"
 )
@@ -10,9 +11,15 @@ type IService interface {
 
 	getConnection(id string) model.TLSDetails
 
-	filterConnections(query url.Values) []model.TLSConnection
+	filterConnections(ctx context.Context, query url.Values) []model.TLSConnection
 
-	buildConnectionsResponse(url string) ([]model.TLSConnection, error)
+	buildConnectionsResponse(ctx context.Context, url string) ([]model.TLSConnection, error)
 
-	buildDetailsResponse(url string) (model.TLSDetails, error)
+	buildDetailsResponse(ctx context.Context, url string) (model.TLSDetails, error)
+
+	snapshotPosture()
+
+	filterPosture(ctx context.Context, query url.Values) []model.TLSPostureSnapshot
+
+	buildTrendResponse(ctx context.Context, url string) ([]model.TLSPosture, error)
 }
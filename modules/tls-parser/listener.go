@@ -19,11 +19,14 @@ type Listener struct {
 func (listener *Listener) Listen(tlsEvent modules.TLSEvent) {
 
 	tlsConnection := model.TLSConnection{
+		SrcId:           tlsEvent.Client.WorkloadId,
 		Src:             tlsEvent.Client.Addr,
 		SrcName:         tlsEvent.Client.Name,
 		SrcNamespace:    tlsEvent.Client.Namespace,
+		DstId:           tlsEvent.Server.WorkloadId,
 		Dst:             tlsEvent.Server.Addr,
 		DstName:         tlsEvent.Server.Name,
+		DstNamespace:    tlsEvent.Server.Namespace,
 		DstPort:         tlsEvent.Server.Port,
 		Domain:          tlsEvent.ServerName,
 		UsedTLSVersion:  dict.ParseTLSVersion(tlsEvent.UsedTlsVersion),
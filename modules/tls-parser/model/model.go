@@ -6,11 +6,14 @@ import (
 
 type TLSConnection struct {
 	Id              string    `json:"id"`
+	SrcId           string    `json:"srcId"`
 	Src             string    `json:"src"`
 	SrcName         string    `json:"srcName"`
 	SrcNamespace    string    `json:"srcNamespace"`
+	DstId           string    `json:"dstId"`
 	Dst             string    `json:"dst"`
 	DstName         string    `json:"dstName"`
+	DstNamespace    string    `json:"dstNamespace"`
 	DstPort         uint16    `json:"dstPort"`
 	Domain          string    `json:"domain"`
 	UsedTLSVersion  string    `json:"usedTLSVersion"`
@@ -18,6 +21,29 @@ type TLSConnection struct {
 	LastSeen        time.Time `json:"lastSeen"`
 }
 
+// TLS versions and cipher suites used by connections seen that day
+type TLSPosture struct {
+	Id           string           `json:"id"`
+	Date         time.Time        `json:"date"`
+	Connections  int64            `json:"connections"`
+	Versions     map[string]int64 `json:"versions"`
+	CipherSuites map[string]int64 `json:"cipherSuites"`
+}
+
+// daily snapshot stored by every instance, the latest handshake of every connection by its id,
+// it leaves the instance only to be merged with snapshots of others, both ends of a connection can be seen by different instances
+type TLSPostureSnapshot struct {
+	Id         string                  `json:"id"`
+	Date       time.Time               `json:"date"`
+	Handshakes map[string]TLSHandshake `json:"handshakes"`
+}
+
+type TLSHandshake struct {
+	UsedTLSVersion  string    `json:"usedTLSVersion"`
+	UsedCipherSuite string    `json:"usedCipherSuite"`
+	LastSeen        time.Time `json:"lastSeen"`
+}
+
 type Certificate struct {
 	NotBefore   time.Time `json:"notBefore"`
 	NotAfter    time.Time `json:"notAfter"`
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/k8spacket/k8spacket/external/db"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/model"
 )
 
@@ -18,29 +19,44 @@ type O11yController struct {
 }
 
 func (o11yController *O11yController) TLSParserConnectionsHandler(w http.ResponseWriter, req *http.Request) {
-	out, err := o11yController.service.buildConnectionsResponse(fmt.Sprintf("http://%%s:%s/tlsparser/connections/?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), req.URL.Query().Encode()))
+	budget := db.NewBudget()
+	out, err := o11yController.service.buildConnectionsResponse(db.WithBudget(req.Context(), budget), fmt.Sprintf("http://%%s:%s/tlsparser/connections/?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), req.URL.Query().Encode()))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	budget.SetHeader(w.Header())
 	prepareResponse(w, out)
 }
 
 func (o11yController *O11yController) TLSParserConnectionDetailsHandler(w http.ResponseWriter, req *http.Request) {
 	idParam := strings.TrimPrefix(req.URL.Path, connectionDetailsUri)
 	if len(strings.TrimSpace(idParam)) > 0 {
-		out, err := o11yController.service.buildDetailsResponse(fmt.Sprintf("http://%%s:%s/tlsparser/connections/%s?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), idParam, req.URL.Query().Encode()))
+		budget := db.NewBudget()
+		out, err := o11yController.service.buildDetailsResponse(db.WithBudget(req.Context(), budget), fmt.Sprintf("http://%%s:%s/tlsparser/connections/%s?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), idParam, req.URL.Query().Encode()))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		budget.SetHeader(w.Header())
 		prepareResponse(w, out)
 	} else {
 		o11yController.TLSParserConnectionsHandler(w, req)
 	}
 }
 
-func prepareResponse[T model.TLSDetails | []model.TLSConnection](w http.ResponseWriter, out T) {
+func (o11yController *O11yController) TLSParserTrendHandler(w http.ResponseWriter, req *http.Request) {
+	budget := db.NewBudget()
+	out, err := o11yController.service.buildTrendResponse(db.WithBudget(req.Context(), budget), fmt.Sprintf("http://%%s:%s/tlsparser/posture?%s", os.Getenv("K8S_PACKET_TCP_LISTENER_PORT"), req.URL.Query().Encode()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	budget.SetHeader(w.Header())
+	prepareResponse(w, out)
+}
+
+func prepareResponse[T model.TLSDetails | []model.TLSConnection | []model.TLSPosture](w http.ResponseWriter, out T) {
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(out)
 	if err != nil {
@@ -1,6 +1,7 @@
 package tlsparser
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,20 +14,65 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func (mockService *mockService) buildConnectionsResponse(url string) ([]model.TLSConnection, error) {
+func (mockService *mockService) buildConnectionsResponse(ctx context.Context, url string) ([]model.TLSConnection, error) {
 	if strings.Contains(url, "scenario=error") {
 		return nil, errors.New("error")
 	}
 	return repo, nil
 }
 
-func (mockService *mockService) buildDetailsResponse(url string) (model.TLSDetails, error) {
+func (mockService *mockService) buildDetailsResponse(ctx context.Context, url string) (model.TLSDetails, error) {
 	if strings.Contains(url, "scenario=error") {
 		return model.TLSDetails{}, errors.New("error")
 	}
 	return repoDetail, nil
 }
 
+func (mockService *mockService) buildTrendResponse(ctx context.Context, url string) ([]model.TLSPosture, error) {
+	if strings.Contains(url, "scenario=error") {
+		return nil, errors.New("error")
+	}
+	return trend, nil
+}
+
+func TestTLSParserTrendHandler(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		want     []model.TLSPosture
+		status   int
+	}{
+		{"ok", trend, http.StatusOK},
+		{"error", nil, http.StatusInternalServerError},
+	}
+
+	service := &mockService{}
+
+	o11yController := O11yController{service}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest("GET", fmt.Sprintf("/tlsparser/api/trend?scenario=%s", test.scenario), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(o11yController.TLSParserTrendHandler)
+			handler.ServeHTTP(rr, req)
+
+			assert.EqualValues(t, test.status, rr.Code)
+
+			var result []model.TLSPosture
+			json.Unmarshal([]byte(rr.Body.String()), &result)
+
+			assert.EqualValues(t, test.want, result)
+		})
+	}
+}
+
 func TestTLSParserConnectionsHandler(t *testing.T) {
 
 	var tests = []struct {
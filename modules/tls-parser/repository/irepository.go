@@ -1,13 +1,17 @@
 package repository
 
 import (
+	"context"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/model"
 	"time"
 )
 
 type IRepository interface {
-	Query(from time.Time, to time.Time) []model.TLSConnection
+	Query(ctx context.Context, from time.Time, to time.Time) []model.TLSConnection
 	UpsertConnection(key string, value *model.TLSConnection)
 	Read(key string) model.TLSDetails
 	UpsertDetails(key string, value *model.TLSDetails, fn Fn)
+	QueryPosture(ctx context.Context, from time.Time, to time.Time) []model.TLSPostureSnapshot
+	UpsertPosture(key string, value *model.TLSPostureSnapshot)
+	DeletePosture(key string)
 }
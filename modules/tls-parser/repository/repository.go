@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"log/slog"
 	"time"
 
@@ -11,11 +12,12 @@ import (
 type Repository struct {
 	DbConnectionHandler db.IDBHandler[model.TLSConnection]
 	DbDetailsHandler    db.IDBHandler[model.TLSDetails]
+	DbPostureHandler    db.IDBHandler[model.TLSPostureSnapshot]
 }
 
-func (repository *Repository) Query(from time.Time, to time.Time) []model.TLSConnection {
+func (repository *Repository) Query(ctx context.Context, from time.Time, to time.Time) []model.TLSConnection {
 
-	query := repository.DbConnectionHandler.QueryMatchFunc("Src", func(record *model.TLSConnection) (bool, error) {
+	query := repository.DbConnectionHandler.QueryMatchFunc(ctx, "Src", func(record *model.TLSConnection) (bool, error) {
 		valid := true
 		if !from.IsZero() {
 			valid = record.LastSeen.After(from) &&
@@ -64,3 +66,41 @@ func (repository *Repository) UpsertDetails(key string, value *model.TLSDetails,
 		slog.Error("[db:tls_details:Upsert]", "Error", err)
 	}
 }
+
+func (repository *Repository) QueryPosture(ctx context.Context, from time.Time, to time.Time) []model.TLSPostureSnapshot {
+
+	query := repository.DbPostureHandler.QueryMatchFunc(ctx, "Date", func(record *model.TLSPostureSnapshot) (bool, error) {
+		valid := true
+		if !from.IsZero() {
+			valid = !record.Date.Before(from) &&
+				valid
+		}
+		if !to.IsZero() {
+			valid = record.Date.Before(to) &&
+				valid
+		}
+
+		return valid, nil
+	})
+
+	result, err := repository.DbPostureHandler.Query(&query)
+	if err != nil {
+		slog.Error("[db:tls_posture:Query]", "Error", err)
+		return []model.TLSPostureSnapshot{}
+	}
+	return result
+}
+
+func (repository *Repository) UpsertPosture(key string, value *model.TLSPostureSnapshot) {
+	err := repository.DbPostureHandler.Upsert(key, value)
+	if err != nil {
+		slog.Error("[db:tls_posture:Upsert]", "Error", err)
+	}
+}
+
+func (repository *Repository) DeletePosture(key string) {
+	err := repository.DbPostureHandler.Delete(key)
+	if err != nil {
+		slog.Error("[db:tls_posture:Delete]", "Error", err)
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log/slog"
 	"testing"
@@ -25,6 +26,17 @@ type mockConnectionDBHandler struct {
 	queryResult []model.TLSConnection
 }
 
+var postureState = []model.TLSPostureSnapshot{
+	model.TLSPostureSnapshot{Id: "2024-01-01", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	model.TLSPostureSnapshot{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	model.TLSPostureSnapshot{Id: "2024-01-03", Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+}
+
+type mockPostureDBHandler struct {
+	DBHandler   db.IDBHandler[model.TLSPostureSnapshot]
+	queryResult []model.TLSPostureSnapshot
+}
+
 type mockDetailsDBHandler struct {
 	DBHandler db.IDBHandler[model.TLSDetails]
 	fnCalled  bool
@@ -37,7 +49,7 @@ func (mock *mockConnectionDBHandler) Query(query *bolthold.Query) ([]model.TLSCo
 	return mock.queryResult, nil
 }
 
-func (mock *mockConnectionDBHandler) QueryMatchFunc(field string, matchFunc func(*model.TLSConnection) (bool, error)) bolthold.Query {
+func (mock *mockConnectionDBHandler) QueryMatchFunc(ctx context.Context, field string, matchFunc func(*model.TLSConnection) (bool, error)) bolthold.Query {
 	mock.queryResult = []model.TLSConnection{}
 	for _, item := range dbState {
 		matched, _ := matchFunc(&item)
@@ -65,11 +77,15 @@ func (mock *mockConnectionDBHandler) Upsert(key string, value *model.TLSConnecti
 	return nil
 }
 
+func (mock *mockConnectionDBHandler) Delete(key string) error {
+	return nil
+}
+
 func (mock *mockDetailsDBHandler) Query(query *bolthold.Query) ([]model.TLSDetails, error) {
 	return []model.TLSDetails{}, nil
 }
 
-func (mock *mockDetailsDBHandler) QueryMatchFunc(field string, matchFunc func(*model.TLSDetails) (bool, error)) bolthold.Query {
+func (mock *mockDetailsDBHandler) QueryMatchFunc(ctx context.Context, field string, matchFunc func(*model.TLSDetails) (bool, error)) bolthold.Query {
 	return bolthold.Query{}
 }
 
@@ -92,6 +108,52 @@ func (mock *mockDetailsDBHandler) Upsert(key string, value *model.TLSDetails) er
 	return nil
 }
 
+func (mock *mockDetailsDBHandler) Delete(key string) error {
+	return nil
+}
+
+func (mock *mockPostureDBHandler) Query(query *bolthold.Query) ([]model.TLSPostureSnapshot, error) {
+	if len(mock.queryResult) == 0 {
+		return []model.TLSPostureSnapshot{}, errors.New("error")
+	}
+	return mock.queryResult, nil
+}
+
+func (mock *mockPostureDBHandler) QueryMatchFunc(ctx context.Context, field string, matchFunc func(*model.TLSPostureSnapshot) (bool, error)) bolthold.Query {
+	mock.queryResult = []model.TLSPostureSnapshot{}
+	for _, item := range postureState {
+		matched, _ := matchFunc(&item)
+		if matched {
+			mock.queryResult = append(mock.queryResult, item)
+		}
+	}
+
+	return bolthold.Query{}
+}
+
+func (mock *mockPostureDBHandler) Close() error {
+	return nil
+}
+
+func (mock *mockPostureDBHandler) Read(key string) (model.TLSPostureSnapshot, error) {
+	return model.TLSPostureSnapshot{}, nil
+}
+
+func (mock *mockPostureDBHandler) Upsert(key string, value *model.TLSPostureSnapshot) error {
+	if key == "error" {
+		return errors.New("error")
+	}
+	value.Handshakes = map[string]model.TLSHandshake{}
+	return nil
+}
+
+func (mock *mockPostureDBHandler) Delete(key string) error {
+	if key == "error" {
+		return errors.New("error")
+	}
+	return nil
+}
+
 func TestQuery(t *testing.T) {
 
 	var str bytes.Buffer
@@ -113,12 +175,12 @@ func TestQuery(t *testing.T) {
 	mockConnectionDBHandler := &mockConnectionDBHandler{}
 	mockDetailsDBHandler := &mockDetailsDBHandler{}
 
-	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler}
+	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler, &mockPostureDBHandler{}}
 
 	for _, test := range tests {
 		t.Run(test.msg, func(t *testing.T) {
 
-			result := repository.Query(test.from, test.to)
+			result := repository.Query(context.Background(), test.from, test.to)
 
 			assert.EqualValues(t, test.want, result)
 			assert.Contains(t, str.String(), test.error)
@@ -140,7 +202,7 @@ func TestRead(t *testing.T) {
 	mockConnectionDBHandler := &mockConnectionDBHandler{}
 	mockDetailsDBHandler := &mockDetailsDBHandler{}
 
-	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler}
+	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler, &mockPostureDBHandler{}}
 
 	for _, test := range tests {
 		t.Run(test.key, func(t *testing.T) {
@@ -173,7 +235,7 @@ func TestUpsertConnection(t *testing.T) {
 	mockConnectionDBHandler := &mockConnectionDBHandler{}
 	mockDetailsDBHandler := &mockDetailsDBHandler{}
 
-	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler}
+	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler, &mockPostureDBHandler{}}
 
 	for _, test := range tests {
 		t.Run(test.key, func(t *testing.T) {
@@ -208,7 +270,7 @@ func TestUpsertDetails(t *testing.T) {
 	mockConnectionDBHandler := &mockConnectionDBHandler{}
 	mockDetailsDBHandler := &mockDetailsDBHandler{}
 
-	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler}
+	repository := Repository{mockConnectionDBHandler, mockDetailsDBHandler, &mockPostureDBHandler{}}
 
 	for _, test := range tests {
 		t.Run(test.key, func(t *testing.T) {
@@ -225,3 +287,70 @@ func TestUpsertDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryPosture(t *testing.T) {
+
+	var str bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&str, nil))
+
+	slog.SetDefault(logger)
+
+	var tests = []struct {
+		msg      string
+		from, to time.Time
+		want     []model.TLSPostureSnapshot
+		error    string
+	}{
+		{"all", time.Time{}, time.Time{}, postureState, ""},
+		{"from / to filter", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), postureState[1:2], ""},
+		{"error", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}, []model.TLSPostureSnapshot{}, "[db:tls_posture:Query] Error=error"},
+	}
+
+	repository := Repository{&mockConnectionDBHandler{}, &mockDetailsDBHandler{}, &mockPostureDBHandler{}}
+
+	for _, test := range tests {
+		t.Run(test.msg, func(t *testing.T) {
+
+			result := repository.QueryPosture(context.Background(), test.from, test.to)
+
+			assert.EqualValues(t, test.want, result)
+			assert.Contains(t, str.String(), test.error)
+		})
+	}
+}
+
+func TestUpsertPosture(t *testing.T) {
+
+	var str bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&str, nil))
+
+	slog.SetDefault(logger)
+
+	repository := Repository{&mockConnectionDBHandler{}, &mockDetailsDBHandler{}, &mockPostureDBHandler{}}
+
+	posture := model.TLSPostureSnapshot{Id: "2024-01-01"}
+	repository.UpsertPosture("key", &posture)
+	assert.NotNil(t, posture.Handshakes)
+
+	repository.UpsertPosture("error", &posture)
+	assert.Contains(t, str.String(), "[db:tls_posture:Upsert] Error=error")
+}
+
+func TestDeletePosture(t *testing.T) {
+
+	var str bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&str, nil))
+
+	slog.SetDefault(logger)
+
+	repository := Repository{&mockConnectionDBHandler{}, &mockDetailsDBHandler{}, &mockPostureDBHandler{}}
+
+	repository.DeletePosture("key")
+	assert.NotContains(t, str.String(), "[db:tls_posture:Delete]")
+
+	repository.DeletePosture("error")
+	assert.Contains(t, str.String(), "[db:tls_posture:Delete] Error=error")
+}
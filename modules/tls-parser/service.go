@@ -1,23 +1,26 @@
 package tlsparser
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"reflect"
+	"slices"
 	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/k8spacket/k8spacket/external/db"
+	"github.com/k8spacket/k8spacket/external/fanout"
+	"github.com/k8spacket/k8spacket/external/hashid"
 	httpclient "github.com/k8spacket/k8spacket/external/http"
 	"github.com/k8spacket/k8spacket/external/k8s"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/certificate"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/model"
 	"github.com/k8spacket/k8spacket/modules/tls-parser/repository"
+	"github.com/k8spacket/k8spacket/pressure"
 )
 
 type Service struct {
@@ -27,19 +30,31 @@ type Service struct {
 	k8sClient   k8sclient.IK8SClient
 }
 
+// connections and their details are stored by workload identifiers, so they are still meaningful after pods get new IPs,
+// details are of the most recent handshake between the workloads
 func (service *Service) storeInDatabase(tlsConnection *model.TLSConnection, tlsDetails *model.TLSDetails) {
-	var id = strconv.Itoa(int(db.HashId(fmt.Sprintf("%s-%s", tlsConnection.Src, tlsConnection.Dst))))
+	var id = connectionId(tlsConnection.SrcId, tlsConnection.DstId)
 	tlsConnection.Id = id
 	service.repo.UpsertConnection(id, tlsConnection)
 	tlsDetails.Id = id
 	service.repo.UpsertDetails(id, tlsDetails, service.certificate.UpdateCertificateInfo)
 }
 
+func connectionId(srcId string, dstId string) string {
+	return strconv.Itoa(int(hashid.HashId(fmt.Sprintf("%s-%s", srcId, dstId))))
+}
+
 func (service *Service) getConnection(id string) model.TLSDetails {
 	return service.repo.Read(id)
 }
 
-func (service *Service) filterConnections(query url.Values) []model.TLSConnection {
+func (service *Service) filterConnections(ctx context.Context, query url.Values) []model.TLSConnection {
+	rangeFrom, rangeTo := parseRange(query)
+	slog.Info("[api:params]", "from", rangeFrom, "to", rangeTo)
+	return service.repo.Query(ctx, rangeFrom, rangeTo)
+}
+
+func parseRange(query url.Values) (time.Time, time.Time) {
 	from := query["from"]
 	rangeFrom := time.Time{}
 	if len(from) > 0 {
@@ -61,61 +76,138 @@ func (service *Service) filterConnections(query url.Values) []model.TLSConnectio
 			rangeTo = time.UnixMilli(i).UTC()
 		}
 	}
-
-	slog.Info("[api:params]", "from", rangeFrom, "to", rangeTo)
-	return service.repo.Query(rangeFrom, rangeTo)
+	return rangeFrom, rangeTo
 }
 
-func (service *Service) buildConnectionsResponse(url string) ([]model.TLSConnection, error) {
-	resultFunc := func(destination, source []model.TLSConnection) []model.TLSConnection {
-		return append(destination, source...)
+// snapshot the current day at startup and then every K8S_PACKET_TLS_POSTURE_REFRESH_PERIOD (1h by default),
+// the timer also wakes up at midnight to write the final snapshot of the previous day before the new one starts,
+// periodic snapshots are skipped while the node is under pressure, the next one catches up,
+// snapshots older than K8S_PACKET_TLS_POSTURE_RETENTION (90 days by default) are removed
+func (service *Service) snapshotPosture() {
+	var refreshPeriod, err = time.ParseDuration(os.Getenv("K8S_PACKET_TLS_POSTURE_REFRESH_PERIOD"))
+	if err != nil || refreshPeriod <= 0 {
+		refreshPeriod = time.Hour
+	}
+	retention, err := time.ParseDuration(os.Getenv("K8S_PACKET_TLS_POSTURE_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 90 * 24 * time.Hour
 	}
-	return buildResponse(service, url, []model.TLSConnection{}, resultFunc)
-}
 
-func (service *Service) buildDetailsResponse(url string) (model.TLSDetails, error) {
-	resultFunc := func(destination, source model.TLSDetails) model.TLSDetails {
-		if !reflect.DeepEqual(source, model.TLSDetails{}) {
-			return source
-		} else {
-			return destination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	now := time.Now().UTC()
+	day := now.Truncate(24 * time.Hour)
+	service.updatePosture(now)
+	service.prunePosture(now.Add(-retention))
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("[posture] Receive signal, exiting...")
+			return
+		case <-time.After(min(refreshPeriod, day.Add(24*time.Hour).Sub(time.Now().UTC()))):
+			now = time.Now().UTC()
+			if now.Truncate(24 * time.Hour).After(day) {
+				service.updatePosture(day.Add(24*time.Hour - time.Nanosecond))
+				day = now.Truncate(24 * time.Hour)
+			}
+			if !pressure.Throttled() {
+				service.updatePosture(now)
+				service.prunePosture(now.Add(-retention))
+			}
 		}
 	}
-	return buildResponse(service, url, model.TLSDetails{}, resultFunc)
 }
 
-func buildResponse[T model.TLSDetails | []model.TLSConnection](service *Service, url string, t T, resultFunc func(d T, s T) T) (T, error) {
-	var k8spacketIps = service.k8sClient.GetPodIPsBySelectors(os.Getenv("K8S_PACKET_API_FIELD_SELECTOR"), os.Getenv("K8S_PACKET_API_LABEL_SELECTOR"))
-
-	var in T
-	out := t
+// every connection (source and destination workloads pair) seen during the day is counted once with its latest TLS version and cipher suite,
+// handshakes are merged into the stored snapshot, so connections seen again after midnight still count for the previous day
+func (service *Service) updatePosture(now time.Time) {
+	day := now.Truncate(24 * time.Hour)
+	snapshot := model.TLSPostureSnapshot{Id: day.Format(time.DateOnly), Date: day, Handshakes: map[string]model.TLSHandshake{}}
+	for _, stored := range service.repo.QueryPosture(context.Background(), day, day.Add(time.Nanosecond)) {
+		mergeHandshakes(snapshot.Handshakes, stored.Handshakes)
+	}
+	handshakes := map[string]model.TLSHandshake{}
+	for _, connection := range service.repo.Query(context.Background(), day, now.Add(time.Nanosecond)) {
+		handshakes[connectionId(connection.SrcId, connection.DstId)] = model.TLSHandshake{UsedTLSVersion: connection.UsedTLSVersion, UsedCipherSuite: connection.UsedCipherSuite, LastSeen: connection.LastSeen}
+	}
+	mergeHandshakes(snapshot.Handshakes, handshakes)
+	service.repo.UpsertPosture(snapshot.Id, &snapshot)
+}
 
-	for _, ip := range k8spacketIps {
-		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf(url, ip), nil)
-		resp, err := service.httpClient.Do(req)
+// snapshots of days before the cutoff are removed
+func (service *Service) prunePosture(cutoff time.Time) {
+	for _, snapshot := range service.repo.QueryPosture(context.Background(), time.Time{}, cutoff.Truncate(24*time.Hour)) {
+		service.repo.DeletePosture(snapshot.Id)
+	}
+}
 
-		if err != nil {
-			slog.Error("[api] Cannot get stats", "Error", err)
-			continue
+// keeps the latest handshake of every connection
+func mergeHandshakes(destination map[string]model.TLSHandshake, handshakes map[string]model.TLSHandshake) {
+	for id, handshake := range handshakes {
+		if current, ok := destination[id]; !ok || handshake.LastSeen.After(current.LastSeen) {
+			destination[id] = handshake
 		}
-		
-		if resp.StatusCode == http.StatusOK {
+	}
+}
 
-			responseData, err := io.ReadAll(resp.Body)
-			if err != nil {
-				slog.Error("[api] Cannot read stats response", "Error", err)
-				continue
-			}
+// versions and cipher suites are counted once per connection
+func postureOf(snapshot model.TLSPostureSnapshot) model.TLSPosture {
+	posture := model.TLSPosture{Id: snapshot.Id, Date: snapshot.Date, Connections: int64(len(snapshot.Handshakes)), Versions: map[string]int64{}, CipherSuites: map[string]int64{}}
+	for _, handshake := range snapshot.Handshakes {
+		posture.Versions[handshake.UsedTLSVersion]++
+		posture.CipherSuites[handshake.UsedCipherSuite]++
+	}
+	return posture
+}
 
-			err = json.Unmarshal(responseData, &in)
-			if err != nil {
-				slog.Error("[api] Cannot parse stats response", "Error", err)
-				continue
+func (service *Service) filterPosture(ctx context.Context, query url.Values) []model.TLSPostureSnapshot {
+	rangeFrom, rangeTo := parseRange(query)
+	return service.repo.QueryPosture(ctx, rangeFrom.Truncate(24*time.Hour), rangeTo)
+}
+
+// snapshots from all k8spacket instances are merged by day, a connection seen by many instances is counted once,
+// handshakes are only needed to merge snapshots, the trend shows the counts
+func (service *Service) buildTrendResponse(ctx context.Context, url string) ([]model.TLSPosture, error) {
+	resultFunc := func(destination, source []model.TLSPostureSnapshot) []model.TLSPostureSnapshot {
+		for _, snapshot := range source {
+			index := slices.IndexFunc(destination, func(s model.TLSPostureSnapshot) bool { return s.Id == snapshot.Id })
+			if index < 0 {
+				destination = append(destination, model.TLSPostureSnapshot{Id: snapshot.Id, Date: snapshot.Date, Handshakes: map[string]model.TLSHandshake{}})
+				index = len(destination) - 1
 			}
+			mergeHandshakes(destination[index].Handshakes, snapshot.Handshakes)
+		}
+		return destination
+	}
+	snapshots, err := buildResponse(ctx, service, url, []model.TLSPostureSnapshot{}, resultFunc)
+	var trend = []model.TLSPosture{}
+	for _, snapshot := range snapshots {
+		trend = append(trend, postureOf(snapshot))
+	}
+	slices.SortFunc(trend, func(a, b model.TLSPosture) int { return a.Date.Compare(b.Date) })
+	return trend, err
+}
 
-			out = resultFunc(out, in)
+func (service *Service) buildConnectionsResponse(ctx context.Context, url string) ([]model.TLSConnection, error) {
+	resultFunc := func(destination, source []model.TLSConnection) []model.TLSConnection {
+		return append(destination, source...)
+	}
+	return buildResponse(ctx, service, url, []model.TLSConnection{}, resultFunc)
+}
+
+func (service *Service) buildDetailsResponse(ctx context.Context, url string) (model.TLSDetails, error) {
+	resultFunc := func(destination, source model.TLSDetails) model.TLSDetails {
+		if !reflect.DeepEqual(source, model.TLSDetails{}) {
+			return source
+		} else {
+			return destination
 		}
 	}
+	return buildResponse(ctx, service, url, model.TLSDetails{}, resultFunc)
+}
 
-	return out, nil
+func buildResponse[T model.TLSDetails | []model.TLSConnection | []model.TLSPostureSnapshot](ctx context.Context, service *Service, url string, t T, resultFunc func(d T, s T) T) (T, error) {
+	return fanout.Get(ctx, service.httpClient, service.k8sClient, url, t, resultFunc), nil
 }
@@ -2,6 +2,7 @@ package tlsparser
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,20 +28,54 @@ var dbState = []model.TLSConnection{
 
 var dbDetails = model.TLSDetails{Id: "id1", UsedTLSVersion: "TLS 1.2"}
 
+var postureState = []model.TLSPostureSnapshot{
+	model.TLSPostureSnapshot{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Handshakes: map[string]model.TLSHandshake{"1": {UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_128_GCM_SHA256"}}},
+	model.TLSPostureSnapshot{Id: "2024-01-01", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Handshakes: map[string]model.TLSHandshake{"1": {UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, "2": {UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}},
+}
+
 type mockRepository struct {
 	repo             repository.IRepository
 	resultConnection model.TLSConnection
 	resultDetails    model.TLSDetails
+	resultPosture    model.TLSPostureSnapshot
+	posture          []model.TLSPostureSnapshot
+	deletedPosture   []string
+	connections      []model.TLSConnection
 	from, to         time.Time
+	connectionKey    string
+	detailsKey       string
 }
 
-func (mockRepository *mockRepository) Query(from time.Time, to time.Time) []model.TLSConnection {
+func (mockRepository *mockRepository) Query(ctx context.Context, from time.Time, to time.Time) []model.TLSConnection {
 	mockRepository.from = from
 	mockRepository.to = to
+	if mockRepository.connections != nil {
+		return mockRepository.connections
+	}
 	return []model.TLSConnection{}
 }
 
+func (mockRepository *mockRepository) QueryPosture(ctx context.Context, from time.Time, to time.Time) []model.TLSPostureSnapshot {
+	mockRepository.from = from
+	mockRepository.to = to
+	if mockRepository.posture != nil {
+		return mockRepository.posture
+	}
+	return []model.TLSPostureSnapshot{}
+}
+
+func (mockRepository *mockRepository) UpsertPosture(key string, value *model.TLSPostureSnapshot) {
+	mockRepository.resultPosture = *value
+}
+
+func (mockRepository *mockRepository) DeletePosture(key string) {
+	mockRepository.deletedPosture = append(mockRepository.deletedPosture, key)
+}
+
 func (mockRepository *mockRepository) UpsertConnection(key string, value *model.TLSConnection) {
+	mockRepository.connectionKey = key
 	mockRepository.resultConnection = *value
 }
 
@@ -49,6 +84,7 @@ func (mockRepository *mockRepository) Read(key string) model.TLSDetails {
 }
 
 func (mockRepository *mockRepository) UpsertDetails(key string, value *model.TLSDetails, fn repository.Fn) {
+	mockRepository.detailsKey = key
 	fn(value, &mockRepository.resultDetails)
 	mockRepository.resultDetails = *value
 }
@@ -104,6 +140,13 @@ func (httpClient *mockHttpClient) Do(req *http.Request) (*http.Response, error)
 			StatusCode: http.StatusOK,
 		}, nil
 	}
+	if req.URL.Query().Get("scenario") == "ok_trend" {
+		result, _ := json.Marshal(postureState)
+		return &http.Response{
+			Body:       io.NopCloser(bytes.NewBuffer(result)),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
 	if req.URL.Query().Get("scenario") == "parse" {
 		result := []byte("parse error")
 		return &http.Response{
@@ -111,7 +154,7 @@ func (httpClient *mockHttpClient) Do(req *http.Request) (*http.Response, error)
 			StatusCode: http.StatusOK,
 		}, nil
 	}
-	return &http.Response{}, nil
+	return &http.Response{Body: http.NoBody}, nil
 }
 
 type BrokenReader struct{}
@@ -126,10 +169,14 @@ func (br *BrokenReader) Close() error {
 
 type mockK8SClient struct {
 	k8sClient k8sclient.IK8SClient
+	ips       []string
 }
 
-func (k8sClient *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) []string {
-	return []string{"127.0.0.1"}
+func (k8sClient *mockK8SClient) GetPodIPsBySelectors(fieldSelector string, labelSelector string) ([]string, error) {
+	if k8sClient.ips != nil {
+		return k8sClient.ips, nil
+	}
+	return []string{"127.0.0.1"}, nil
 }
 
 func TestStoreInDatabase(t *testing.T) {
@@ -139,7 +186,7 @@ func TestStoreInDatabase(t *testing.T) {
 
 	service := Service{mockRepository, mockCertificate, &httpclient.HttpClient{}, &k8sclient.K8SClient{}}
 
-	tlsConnection := model.TLSConnection{Src: "src"}
+	tlsConnection := model.TLSConnection{SrcId: "srcId", Src: "src", DstId: "dstId"}
 	tlsDetails := model.TLSDetails{UsedTLSVersion: "TLS 1.2"}
 
 	service.storeInDatabase(&tlsConnection, &tlsDetails)
@@ -152,6 +199,19 @@ func TestStoreInDatabase(t *testing.T) {
 
 	assert.EqualValues(t, true, mockCertificate.fnCalled)
 
+	assert.EqualValues(t, tlsConnection.Id, mockRepository.detailsKey)
+	connectionKey := mockRepository.connectionKey
+
+	assert.EqualValues(t, connectionKey, mockRepository.detailsKey)
+
+	// the same workloads with new IPs share the connection and details records
+	rescheduled := model.TLSConnection{SrcId: "srcId", Src: "src2", DstId: "dstId"}
+	service.storeInDatabase(&rescheduled, &model.TLSDetails{})
+
+	assert.EqualValues(t, connectionKey, mockRepository.connectionKey)
+	assert.EqualValues(t, tlsConnection.Id, rescheduled.Id)
+	assert.EqualValues(t, connectionKey, mockRepository.detailsKey)
+
 }
 
 func TestRead(t *testing.T) {
@@ -192,7 +252,7 @@ func TestFilterConnections(t *testing.T) {
 			query.Add("from", test.from)
 			query.Add("to", test.to)
 
-			service.filterConnections(query)
+			service.filterConnections(context.Background(), query)
 
 			assert.EqualValues(t, test.wantFrom, mockRepository.from)
 			assert.EqualValues(t, test.wantTo, mockRepository.to)
@@ -231,7 +291,7 @@ func TestBuildConnectionsResponse(t *testing.T) {
 
 			url := fmt.Sprintf("http://%%s:6676/tlsparser/connections/?scenario=%s", test.scenario)
 
-			result, _ := service.buildConnectionsResponse(url)
+			result, _ := service.buildConnectionsResponse(context.Background(), url)
 
 			assert.EqualValues(t, test.want, result)
 			assert.Contains(t, str.String(), test.error)
@@ -271,7 +331,7 @@ func TestBuildDetailsResponse(t *testing.T) {
 
 			url := fmt.Sprintf("http://%%s:6676/tlsparser/connections/%s?scenario=%s", "id1", test.scenario)
 
-			result, _ := service.buildDetailsResponse(url)
+			result, _ := service.buildDetailsResponse(context.Background(), url)
 
 			assert.EqualValues(t, test.want, result)
 			assert.Contains(t, str.String(), test.error)
@@ -280,3 +340,121 @@ func TestBuildDetailsResponse(t *testing.T) {
 	}
 
 }
+
+func TestUpdatePosture(t *testing.T) {
+
+	lastSeen := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	mockRepository := &mockRepository{connections: []model.TLSConnection{
+		{SrcId: "a", DstId: "b", UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_128_GCM_SHA256", LastSeen: lastSeen},
+		{SrcId: "a", DstId: "c", UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_256_GCM_SHA384", LastSeen: lastSeen},
+		{SrcId: "b", DstId: "c", UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", LastSeen: lastSeen},
+	}}
+	service := &Service{mockRepository, &certificate.Certificate{}, &mockHttpClient{}, &mockK8SClient{}}
+
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	service.updatePosture(now)
+
+	assert.EqualValues(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), mockRepository.from)
+	assert.True(t, mockRepository.to.After(now))
+	assert.EqualValues(t, model.TLSPostureSnapshot{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Handshakes: map[string]model.TLSHandshake{
+			connectionId("a", "b"): {UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_128_GCM_SHA256", LastSeen: lastSeen},
+			connectionId("a", "c"): {UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_256_GCM_SHA384", LastSeen: lastSeen},
+			connectionId("b", "c"): {UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", LastSeen: lastSeen}}},
+		mockRepository.resultPosture)
+}
+
+func TestUpdatePostureMergesStoredSnapshot(t *testing.T) {
+
+	earlier := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	later := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	mockRepository := &mockRepository{
+		connections: []model.TLSConnection{
+			{SrcId: "a", DstId: "b", UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_128_GCM_SHA256", LastSeen: later},
+		},
+		posture: []model.TLSPostureSnapshot{{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Handshakes: map[string]model.TLSHandshake{
+			connectionId("a", "b"): {UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", LastSeen: earlier},
+			// seen again after midnight, so it's gone from the connections of the day
+			connectionId("a", "c"): {UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", LastSeen: earlier}}}},
+	}
+	service := &Service{mockRepository, &certificate.Certificate{}, &mockHttpClient{}, &mockK8SClient{}}
+
+	service.updatePosture(time.Date(2024, 1, 2, 23, 59, 59, 0, time.UTC))
+
+	assert.EqualValues(t, 2, len(mockRepository.resultPosture.Handshakes))
+	assert.EqualValues(t, "TLS 1.3", mockRepository.resultPosture.Handshakes[connectionId("a", "b")].UsedTLSVersion)
+	assert.EqualValues(t, later, mockRepository.resultPosture.Handshakes[connectionId("a", "b")].LastSeen)
+}
+
+func TestPostureOf(t *testing.T) {
+
+	posture := postureOf(model.TLSPostureSnapshot{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Handshakes: map[string]model.TLSHandshake{
+		connectionId("a", "b"): {UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_128_GCM_SHA256"},
+		connectionId("a", "c"): {UsedTLSVersion: "TLS 1.3", UsedCipherSuite: "TLS_AES_256_GCM_SHA384"},
+		connectionId("b", "c"): {UsedTLSVersion: "TLS 1.2", UsedCipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}})
+
+	assert.EqualValues(t, model.TLSPosture{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Connections: 3,
+		Versions:     map[string]int64{"TLS 1.3": 2, "TLS 1.2": 1},
+		CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 1, "TLS_AES_256_GCM_SHA384": 1, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 1}},
+		posture)
+}
+
+func TestPrunePosture(t *testing.T) {
+
+	mockRepository := &mockRepository{posture: []model.TLSPostureSnapshot{{Id: "2023-10-01"}, {Id: "2023-10-02"}}}
+	service := &Service{mockRepository, &certificate.Certificate{}, &mockHttpClient{}, &mockK8SClient{}}
+
+	service.prunePosture(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	assert.True(t, mockRepository.from.IsZero())
+	assert.EqualValues(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), mockRepository.to)
+	assert.EqualValues(t, []string{"2023-10-01", "2023-10-02"}, mockRepository.deletedPosture)
+}
+
+func TestFilterPosture(t *testing.T) {
+
+	mockRepository := &mockRepository{}
+	service := &Service{mockRepository, &certificate.Certificate{}, &mockHttpClient{}, &mockK8SClient{}}
+
+	service.filterPosture(context.Background(), url.Values{"from": {"1704207845000"}, "to": {"1704380645000"}})
+
+	assert.EqualValues(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), mockRepository.from)
+	assert.EqualValues(t, time.Date(2024, 1, 4, 15, 4, 5, 0, time.UTC), mockRepository.to)
+}
+
+func TestBuildTrendResponse(t *testing.T) {
+
+	var str bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&str, nil))
+
+	slog.SetDefault(logger)
+
+	var tests = []struct {
+		scenario string
+		want     []model.TLSPosture
+		error    string
+	}{
+		{"ok_trend", []model.TLSPosture{
+			// both instances have seen the same connections
+			{Id: "2024-01-01", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Connections: 2, Versions: map[string]int64{"TLS 1.2": 2}, CipherSuites: map[string]int64{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 2}},
+			{Id: "2024-01-02", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Connections: 1, Versions: map[string]int64{"TLS 1.3": 1}, CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 1}},
+		}, ""},
+		{"error", []model.TLSPosture{}, "[api] Cannot get stats"},
+		{"parse", []model.TLSPosture{}, "[api] Cannot parse stats response"},
+	}
+
+	service := Service{&repository.Repository{}, &certificate.Certificate{}, &mockHttpClient{}, &mockK8SClient{ips: []string{"10.0.0.1", "10.0.0.2"}}}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			url := fmt.Sprintf("http://%%s:6676/tlsparser/posture?scenario=%s", test.scenario)
+
+			result, _ := service.buildTrendResponse(context.Background(), url)
+
+			assert.EqualValues(t, test.want, result)
+			assert.Contains(t, str.String(), test.error)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package pressure
+
+type IMonitor interface {
+	Watch()
+}
+
+type IThrottler interface {
+	Throttle(throttled bool)
+}
@@ -0,0 +1,137 @@
+package pressure
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type resource struct {
+	name      string
+	file      string
+	threshold float64
+}
+
+type Monitor struct {
+	throttlers []IThrottler
+	resources  []resource
+}
+
+// set while the node is under pressure, heavy optional work (webhooks, snapshots, cluster-wide queries) is postponed or skipped
+var throttled atomic.Bool
+
+func Throttled() bool {
+	return throttled.Load()
+}
+
+// throttlers (the broker, the tc programs) are notified when the node gets under pressure and when it subsides
+func Init(throttlers ...IThrottler) *Monitor {
+	// /proc/pressure is not namespaced, it reports pressure of the whole node even inside the k8spacket container
+	return &Monitor{throttlers: throttlers, resources: []resource{
+		{"cpu", "/proc/pressure/cpu", threshold("K8S_PACKET_PRESSURE_CPU_THRESHOLD", 80)},
+		{"memory", "/proc/pressure/memory", threshold("K8S_PACKET_PRESSURE_MEMORY_THRESHOLD", 40)},
+	}}
+}
+
+// percentage of time (avg10) in which some tasks were stalled, a non-positive value disables checking the resource
+func threshold(name string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func (monitor *Monitor) Watch() {
+	enabled, _ := strconv.ParseBool(os.Getenv("K8S_PACKET_PRESSURE_THROTTLING_ENABLED"))
+	if !enabled {
+		return
+	}
+
+	refreshPeriod, err := time.ParseDuration(os.Getenv("K8S_PACKET_PRESSURE_REFRESH_PERIOD"))
+	if err != nil {
+		refreshPeriod = 10 * time.Second
+	}
+	if refreshPeriod <= 0 {
+		slog.Info("[pressure] Non-positive refresh period, throttling disabled")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("[pressure] Receive signal, exiting...")
+			return
+		case <-time.After(refreshPeriod):
+			monitor.check()
+		}
+	}
+}
+
+// throttle when any resource exceeds its threshold, release when all of them drop below half of the threshold,
+// a resource which cannot be read keeps the current state
+func (monitor *Monitor) check() {
+	var exceeded, subsided = false, true
+	for _, res := range monitor.resources {
+		if res.threshold <= 0 {
+			continue
+		}
+		value, err := readPressure(res.file)
+		if err != nil {
+			slog.Error("[pressure] Cannot read pressure", "resource", res.name, "Error", err)
+			subsided = false
+			continue
+		}
+		if value >= res.threshold {
+			exceeded = true
+		}
+		if value >= res.threshold/2 {
+			subsided = false
+		}
+	}
+
+	if exceeded && !throttled.Load() {
+		slog.Warn("[pressure] Node under pressure, throttling")
+		monitor.throttle(true)
+	} else if subsided && throttled.Load() {
+		slog.Info("[pressure] Pressure subsided, releasing throttling")
+		monitor.throttle(false)
+	}
+}
+
+func (monitor *Monitor) throttle(value bool) {
+	throttled.Store(value)
+	for _, throttler := range monitor.throttlers {
+		throttler.Throttle(value)
+	}
+}
+
+// PSI format, e.g. `some avg10=1.53 avg60=0.87 avg300=0.23 total=1234567`, returns avg10 of the `some` line
+func readPressure(name string) (float64, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, errors.New("pressure stall information is not available")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		return strconv.ParseFloat(strings.TrimPrefix(fields[1], "avg10="), 64)
+	}
+	return 0, errors.New("no `some` line in " + name)
+}
@@ -0,0 +1,127 @@
+package pressure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k8spacket/k8spacket/broker"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBroker struct {
+	broker.IBroker
+	throttled    bool
+	throttleCall int
+}
+
+func (mockBroker *mockBroker) Throttle(throttled bool) {
+	mockBroker.throttled = throttled
+	mockBroker.throttleCall++
+}
+
+func writePressure(t *testing.T, file string, avg10 string) {
+	content := "some avg10=" + avg10 + " avg60=0.00 avg300=0.00 total=100\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=10\n"
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+
+	cpu := filepath.Join(t.TempDir(), "cpu.pressure")
+	memory := filepath.Join(t.TempDir(), "memory.pressure")
+
+	var tests = []struct {
+		scenario          string
+		cpu, memory       string
+		wantThrottled     bool
+		wantThrottleCalls int
+	}{
+		{"calm", "1.00", "1.00", false, 0},
+		{"cpu pressure", "60.00", "1.00", true, 1},
+		{"still above half", "30.00", "1.00", true, 1},
+		{"subsided", "10.00", "1.00", false, 2},
+		{"memory pressure", "10.00", "25.00", true, 3},
+		{"memory subsided", "10.00", "5.00", false, 4},
+		{"cpu pressure again", "60.00", "5.00", true, 5},
+		// the state is kept while a resource cannot be read
+		{"memory not readable", "1.00", "", true, 5},
+		{"memory readable again", "1.00", "1.00", false, 6},
+	}
+
+	defer throttled.Store(false)
+	mockTc := &mockBroker{}
+	mockBroker := &mockBroker{}
+	monitor := &Monitor{throttlers: []IThrottler{mockBroker, mockTc}, resources: []resource{
+		{"cpu", cpu, 50},
+		{"memory", memory, 20},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			writePressure(t, cpu, test.cpu)
+			if test.memory == "" {
+				os.Remove(memory)
+			} else {
+				writePressure(t, memory, test.memory)
+			}
+
+			monitor.check()
+
+			assert.EqualValues(t, test.wantThrottled, mockBroker.throttled)
+			assert.EqualValues(t, test.wantThrottleCalls, mockBroker.throttleCall)
+			assert.EqualValues(t, test.wantThrottled, mockTc.throttled)
+			assert.EqualValues(t, test.wantThrottled, Throttled())
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+
+	t.Setenv("K8S_PACKET_PRESSURE_CPU_THRESHOLD", "")
+	t.Setenv("K8S_PACKET_PRESSURE_MEMORY_THRESHOLD", "0")
+
+	monitor := Init(&mockBroker{})
+
+	assert.EqualValues(t, []resource{
+		{"cpu", "/proc/pressure/cpu", 80},
+		{"memory", "/proc/pressure/memory", 0},
+	}, monitor.resources)
+}
+
+func TestWatchDisabled(t *testing.T) {
+
+	t.Setenv("K8S_PACKET_PRESSURE_THROTTLING_ENABLED", "true")
+	t.Setenv("K8S_PACKET_PRESSURE_REFRESH_PERIOD", "0s")
+
+	done := make(chan bool)
+	go func() {
+		Init(&mockBroker{}).Watch()
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher should not run with non-positive refresh period")
+	}
+}
+
+func TestReadPressure(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "cpu.pressure")
+	writePressure(t, file, "12.34")
+
+	value, err := readPressure(file)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 12.34, value)
+
+	_, err = readPressure("/not/existing")
+	assert.EqualError(t, err, "pressure stall information is not available")
+
+	os.WriteFile(file, []byte("full avg10=1.00"), 0600)
+	_, err = readPressure(file)
+	assert.EqualError(t, err, "no `some` line in "+file)
+}
@@ -0,0 +1,7 @@
+package ratelimit
+
+import "net/http"
+
+type ILimiter interface {
+	Handler(next http.Handler) http.Handler
+}
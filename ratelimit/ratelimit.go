@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queried by other k8spacket instances fanning out API requests, the originating request is limited already,
+// but these scans are heavy, so all of them share one limit of queries in progress instead of per client buckets
+var internalPaths = []string{"/nodegraph/connections", "/tlsparser/connections/", "/tlsparser/posture", "/telemetry/counters", "/firstseen/destinations", "/loopback/connections"}
+
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	inFlight int
+}
+
+type Limiter struct {
+	rate             float64
+	burst            float64
+	maxConcurrent    int
+	maxInternal      int
+	internalInFlight int
+	exemptPaths      []string
+	buckets          map[string]*bucket
+	pruned           time.Time
+	mutex            sync.Mutex
+	now              func() time.Time
+}
+
+func Init() *Limiter {
+	rate, _ := strconv.ParseFloat(os.Getenv("K8S_PACKET_API_RATE_LIMIT"), 64)
+	burst, err := strconv.ParseFloat(os.Getenv("K8S_PACKET_API_RATE_BURST"), 64)
+	if err != nil || burst < 1 {
+		burst = math.Max(1, math.Ceil(rate))
+	}
+	maxConcurrent, _ := strconv.Atoi(os.Getenv("K8S_PACKET_API_MAX_CONCURRENT_QUERIES"))
+	maxInternal, err := strconv.Atoi(os.Getenv("K8S_PACKET_API_MAX_CONCURRENT_INTERNAL_QUERIES"))
+	if err != nil {
+		maxInternal = 4
+	}
+	exemptPaths, found := os.LookupEnv("K8S_PACKET_API_RATE_LIMIT_EXEMPT_PATHS")
+	if !found {
+		exemptPaths = "/metrics"
+	}
+
+	limiter := &Limiter{rate: rate, burst: burst, maxConcurrent: maxConcurrent, maxInternal: maxInternal, buckets: make(map[string]*bucket), now: time.Now}
+	for _, path := range strings.Split(exemptPaths, ",") {
+		if strings.TrimSpace(path) != "" {
+			limiter.exemptPaths = append(limiter.exemptPaths, strings.TrimSpace(path))
+		}
+	}
+	return limiter
+}
+
+// heavy queries of dashboards or scripts compete with the capture pipeline for CPU,
+// so every client IP gets its own token bucket and limit of queries in progress
+func (limiter *Limiter) Handler(next http.Handler) http.Handler {
+	if limiter.rate <= 0 && limiter.maxConcurrent <= 0 && limiter.maxInternal <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if matchPath(internalPaths, r.URL.Path) {
+			if !limiter.acquireInternal() {
+				slog.Debug("[api] Internal request rejected by concurrency limit", "path", r.URL.Path)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			defer limiter.releaseInternal()
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if matchPath(limiter.exemptPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := clientKey(r)
+		retryAfter, allowed := limiter.acquire(client)
+		if !allowed {
+			slog.Debug("[api] Request rejected by rate limit", "path", r.URL.Path, "retryAfter", retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		defer limiter.release(client)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// paths ending with "/" match all paths below them
+func matchPath(paths []string, path string) bool {
+	return slices.ContainsFunc(paths, func(matchingPath string) bool {
+		return path == matchingPath || (strings.HasSuffix(matchingPath, "/") && strings.HasPrefix(path, matchingPath))
+	})
+}
+
+// headers like Authorization are not verified by k8spacket, a client could get a fresh bucket with every made up value
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// returns seconds to wait before the next attempt when the request is not allowed
+func (limiter *Limiter) acquire(client string) (int, bool) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	limiter.prune(now)
+
+	b, found := limiter.buckets[client]
+	if !found {
+		b = &bucket{tokens: limiter.burst, updated: now}
+		limiter.buckets[client] = b
+	}
+
+	if limiter.maxConcurrent > 0 && b.inFlight >= limiter.maxConcurrent {
+		return 1, false
+	}
+
+	if limiter.rate > 0 {
+		b.tokens = math.Min(limiter.burst, b.tokens+now.Sub(b.updated).Seconds()*limiter.rate)
+		b.updated = now
+		if b.tokens < 1 {
+			return int(math.Ceil((1 - b.tokens) / limiter.rate)), false
+		}
+		b.tokens--
+	}
+
+	b.inFlight++
+	return 0, true
+}
+
+func (limiter *Limiter) acquireInternal() bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if limiter.maxInternal > 0 && limiter.internalInFlight >= limiter.maxInternal {
+		return false
+	}
+	limiter.internalInFlight++
+	return true
+}
+
+func (limiter *Limiter) releaseInternal() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.internalInFlight--
+}
+
+func (limiter *Limiter) release(client string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if b, found := limiter.buckets[client]; found {
+		b.inFlight--
+	}
+}
+
+// forget idle clients, their buckets would be full again anyway
+func (limiter *Limiter) prune(now time.Time) {
+	if now.Sub(limiter.pruned) < time.Minute {
+		return
+	}
+	limiter.pruned = now
+
+	var refill = time.Minute
+	if limiter.rate > 0 {
+		refill = max(refill, time.Duration(limiter.burst/limiter.rate*float64(time.Second)))
+	}
+	for client, b := range limiter.buckets {
+		if b.inFlight == 0 && now.Sub(b.updated) > refill {
+			delete(limiter.buckets, client)
+		}
+	}
+}
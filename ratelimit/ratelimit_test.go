@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit(t *testing.T) {
+
+	var tests = []struct {
+		scenario      string
+		env           map[string]string
+		rate          float64
+		burst         float64
+		maxConcurrent int
+		maxInternal   int
+		exemptPaths   []string
+	}{
+		{"disabled", map[string]string{}, 0, 1, 0, 4, []string{"/metrics"}},
+		{"default burst", map[string]string{"K8S_PACKET_API_RATE_LIMIT": "2.5"}, 2.5, 3, 0, 4, []string{"/metrics"}},
+		{"custom", map[string]string{"K8S_PACKET_API_RATE_LIMIT": "1", "K8S_PACKET_API_RATE_BURST": "10", "K8S_PACKET_API_MAX_CONCURRENT_QUERIES": "2", "K8S_PACKET_API_MAX_CONCURRENT_INTERNAL_QUERIES": "0", "K8S_PACKET_API_RATE_LIMIT_EXEMPT_PATHS": "/metrics, /healthz"}, 1, 10, 2, 0, []string{"/metrics", "/healthz"}},
+		{"nothing exempt", map[string]string{"K8S_PACKET_API_RATE_LIMIT_EXEMPT_PATHS": ""}, 0, 1, 0, 4, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			for key, value := range test.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+
+			limiter := Init()
+
+			assert.EqualValues(t, test.rate, limiter.rate)
+			assert.EqualValues(t, test.burst, limiter.burst)
+			assert.EqualValues(t, test.maxConcurrent, limiter.maxConcurrent)
+			assert.EqualValues(t, test.maxInternal, limiter.maxInternal)
+			assert.EqualValues(t, test.exemptPaths, limiter.exemptPaths)
+		})
+	}
+}
+
+func TestHandlerRate(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &Limiter{rate: 0.5, burst: 2, exemptPaths: []string{"/metrics", "/debug/"}, buckets: make(map[string]*bucket), now: func() time.Time { return now }}
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var tests = []struct {
+		scenario   string
+		path       string
+		remoteAddr string
+		token      string
+		elapsed    time.Duration
+		status     int
+		retryAfter string
+	}{
+		{"first", "/nodegraph/api/graph/data", "10.0.0.1:1234", "", 0, http.StatusOK, ""},
+		{"burst", "/nodegraph/api/graph/data", "10.0.0.1:2345", "", 0, http.StatusOK, ""},
+		{"limited", "/nodegraph/api/graph/data", "10.0.0.1:3456", "", 0, http.StatusTooManyRequests, "2"},
+		{"exempt", "/metrics", "10.0.0.1:3456", "", 0, http.StatusOK, ""},
+		{"exempt below", "/debug/pprof", "10.0.0.1:3456", "", 0, http.StatusOK, ""},
+		{"internal", "/tlsparser/connections/123", "10.0.0.1:3456", "", 0, http.StatusOK, ""},
+		{"not below", "/metrics/other", "10.0.0.1:3456", "", 0, http.StatusTooManyRequests, "2"},
+		{"other ip", "/nodegraph/api/graph/data", "10.0.0.2:1234", "", 0, http.StatusOK, ""},
+		{"token ignored", "/nodegraph/api/graph/data", "10.0.0.1:1234", "grafana", 0, http.StatusTooManyRequests, "2"},
+		{"partially refilled", "/nodegraph/api/graph/data", "10.0.0.1:1234", "", time.Second, http.StatusTooManyRequests, "1"},
+		{"refilled", "/nodegraph/api/graph/data", "10.0.0.1:1234", "", time.Second, http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			now = now.Add(test.elapsed)
+
+			req, _ := http.NewRequest(http.MethodGet, test.path, nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.EqualValues(t, test.status, rr.Code)
+			assert.EqualValues(t, test.retryAfter, rr.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestHandlerConcurrency(t *testing.T) {
+
+	limiter := &Limiter{maxConcurrent: 1, buckets: make(map[string]*bucket), now: time.Now}
+
+	started := make(chan bool)
+	finish := make(chan bool)
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") == "true" {
+			started <- true
+			<-finish
+		}
+	}))
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	go serve("/tlsparser/api/data?slow=true")
+	<-started
+
+	rr := serve("/tlsparser/api/data")
+	assert.EqualValues(t, http.StatusTooManyRequests, rr.Code)
+	assert.EqualValues(t, "1", rr.Header().Get("Retry-After"))
+
+	finish <- true
+	assert.Eventually(t, func() bool {
+		return serve("/tlsparser/api/data").Code == http.StatusOK
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestHandlerInternalConcurrency(t *testing.T) {
+
+	limiter := &Limiter{maxInternal: 1, buckets: make(map[string]*bucket), now: time.Now}
+
+	started := make(chan bool)
+	finish := make(chan bool)
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") == "true" {
+			started <- true
+			<-finish
+		}
+	}))
+
+	serve := func(url string, remoteAddr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	go serve("/nodegraph/connections?slow=true", "10.0.0.1:1234")
+	<-started
+
+	// the limit is shared by all instances
+	rr := serve("/tlsparser/connections/", "10.0.0.2:1234")
+	assert.EqualValues(t, http.StatusTooManyRequests, rr.Code)
+	assert.EqualValues(t, "1", rr.Header().Get("Retry-After"))
+
+	// API requests are not limited by internal ones
+	assert.EqualValues(t, http.StatusOK, serve("/nodegraph/api/graph/data", "10.0.0.2:1234").Code)
+
+	finish <- true
+	assert.Eventually(t, func() bool {
+		return serve("/tlsparser/connections/", "10.0.0.2:1234").Code == http.StatusOK
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestPrune(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &Limiter{rate: 1, burst: 5, buckets: map[string]*bucket{
+		"idle":      {updated: now.Add(-time.Hour)},
+		"in flight": {updated: now.Add(-time.Hour), inFlight: 1},
+		"recent":    {updated: now.Add(-time.Second)},
+	}}
+
+	limiter.prune(now)
+
+	assert.NotContains(t, limiter.buckets, "idle")
+	assert.Contains(t, limiter.buckets, "in flight")
+	assert.Contains(t, limiter.buckets, "recent")
+}
@@ -0,0 +1,97 @@
+package security
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Capability struct {
+	Bit      uint
+	Name     string
+	Reason   string
+	Optional bool
+}
+
+// capabilities needed by the agent instead of the full privileged mode, see include/uapi/linux/capability.h
+var Capabilities = []Capability{
+	{39, "BPF", "load eBPF programs and create maps", false},
+	{38, "PERFMON", "attach the inet_sock_set_state tracepoint and read perf events", false},
+	{12, "NET_ADMIN", "add clsact qdisc and tc filters on network interfaces", false},
+	{2, "DAC_READ_SEARCH", "read tracepoint ids from tracefs (/sys/kernel/tracing) as a non-root user", false},
+	{24, "SYS_RESOURCE", "remove the memlock rlimit on kernels older than 5.11, which account eBPF memory against it", true},
+}
+
+var statusFile = "/proc/self/status"
+
+// effective capabilities of the current process based on the CapEff entry of /proc/self/status
+func effectiveCapabilities() (uint64, error) {
+	file, err := os.Open(statusFile)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if found {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("CapEff not found in " + statusFile)
+}
+
+// returns missing required capabilities as an error and missing optional ones as a list of warnings
+func VerifyCapabilities() ([]string, error) {
+	effective, err := effectiveCapabilities()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read effective capabilities: %w", err)
+	}
+
+	var missing, warnings []string
+	for _, capability := range Capabilities {
+		if effective&(1<<capability.Bit) != 0 {
+			continue
+		}
+		message := fmt.Sprintf("CAP_%s (needed to %s)", capability.Name, capability.Reason)
+		if capability.Optional {
+			warnings = append(warnings, message)
+		} else {
+			missing = append(missing, message)
+		}
+	}
+
+	if len(missing) > 0 {
+		return warnings, fmt.Errorf("missing capabilities: %s; add them to the container securityContext, run with --print-required-caps to get the minimal one", strings.Join(missing, ", "))
+	}
+	return warnings, nil
+}
+
+// minimal container securityContext, the binary has file capabilities set in the image so the agent can run as non-root
+func PrintRequiredCaps(w io.Writer) {
+	fmt.Fprintln(w, "securityContext:")
+	fmt.Fprintln(w, "  runAsNonRoot: true")
+	fmt.Fprintln(w, "  runAsUser: 65532")
+	fmt.Fprintln(w, "  # kernels older than 5.11 and interfaces commands needing root require runAsUser: 0, see README")
+	fmt.Fprintln(w, "  # file capabilities of the binary are granted on exec")
+	fmt.Fprintln(w, "  allowPrivilegeEscalation: true")
+	fmt.Fprintln(w, "  capabilities:")
+	fmt.Fprintln(w, "    drop:")
+	fmt.Fprintln(w, "      - ALL")
+	fmt.Fprintln(w, "    add:")
+	for _, capability := range Capabilities {
+		if capability.Optional {
+			fmt.Fprintf(w, "      - %s # optional, %s\n", capability.Name, capability.Reason)
+		} else {
+			fmt.Fprintf(w, "      - %s # %s\n", capability.Name, capability.Reason)
+		}
+	}
+}
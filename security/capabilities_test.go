@@ -0,0 +1,57 @@
+package security
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCapabilities(t *testing.T) {
+
+	var tests = []struct {
+		scenario string
+		status   string
+		warnings int
+		err      string
+	}{
+		{"all capabilities", "Name:\tk8spacket\nCapEff:\t000001ffffffffff\n", 0, ""},
+		{"required only", "CapEff:\t000000c000041004\n", 1, ""},
+		{"missing BPF", "CapEff:\t0000004000041004\n", 1, "missing capabilities: CAP_BPF (needed to load eBPF programs and create maps); add them to the container securityContext, run with --print-required-caps to get the minimal one"},
+		{"none", "CapEff:\t0000000000000000\n", 1, "missing capabilities: CAP_BPF (needed to load eBPF programs and create maps), CAP_PERFMON"},
+		{"no CapEff", "Name:\tk8spacket\n", 0, "cannot read effective capabilities: CapEff not found"},
+		{"malformed", "CapEff:\tzz\n", 0, "cannot read effective capabilities: strconv.ParseUint"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+
+			statusFile = filepath.Join(t.TempDir(), "status")
+			os.WriteFile(statusFile, []byte(test.status), 0600)
+
+			warnings, err := VerifyCapabilities()
+
+			assert.Len(t, warnings, test.warnings)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestPrintRequiredCaps(t *testing.T) {
+
+	var out bytes.Buffer
+
+	PrintRequiredCaps(&out)
+
+	assert.Contains(t, out.String(), "securityContext:\n  runAsNonRoot: true\n")
+	assert.Contains(t, out.String(), "    drop:\n      - ALL\n    add:\n      - BPF # load eBPF programs and create maps\n")
+	assert.Contains(t, out.String(), "      - NET_ADMIN # add clsact qdisc and tc filters on network interfaces\n")
+	assert.Contains(t, out.String(), "      - SYS_RESOURCE # optional, ")
+	assert.Contains(t, out.String(), "  # kernels older than 5.11 and interfaces commands needing root require runAsUser: 0, see README\n")
+}
@@ -0,0 +1,151 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+const (
+	FeatureRuntime = "runtime"
+	FeatureNetwork = "network"
+	FeatureEbpf    = "ebpf"
+	FeatureTc      = "tc"
+	FeatureExec    = "exec"
+	FeatureDB      = "db"
+)
+
+// syscalls needed by features of the agent, names missing on the target architecture are skipped by the container runtime,
+// the lists are a baseline, syscalls added to the generated profile file by hand are kept when it is rewritten
+var Features = map[string][]string{
+	FeatureRuntime: {"read", "write", "close", "open", "openat", "fstat", "newfstatat", "stat", "lstat", "lseek", "pread64", "readlinkat",
+		"getdents64", "mmap", "munmap", "mprotect", "madvise", "brk", "futex", "clone", "clone3", "exit", "exit_group",
+		"rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "sigaltstack", "gettid", "getpid", "getppid", "tgkill", "nanosleep",
+		"clock_gettime", "clock_nanosleep", "sched_yield", "sched_getaffinity", "getrandom", "uname", "fcntl", "arch_prctl",
+		"set_tid_address", "set_robust_list", "rseq", "prlimit64", "epoll_create1", "epoll_ctl", "epoll_pwait", "epoll_wait",
+		"eventfd2", "pipe2", "getuid", "geteuid", "getgid", "getegid", "capget"},
+	FeatureNetwork: {"socket", "bind", "listen", "accept4", "connect", "getsockname", "getpeername", "setsockopt", "getsockopt",
+		"sendto", "recvfrom", "sendmsg", "recvmsg", "shutdown"},
+	FeatureEbpf: {"bpf", "perf_event_open", "ioctl", "setrlimit", "statfs", "fstatfs"},
+	FeatureTc:   {"socket", "bind", "sendto", "recvfrom", "sendmsg", "recvmsg", "setsockopt", "getsockname"},
+	// children inherit the filter of the agent, so it covers busybox sh, ip and nft linked against musl in the image as well
+	FeatureExec: {"execve", "wait4", "waitid", "dup3", "vfork", "kill", "faccessat", "faccessat2", "pidfd_open",
+		"pidfd_send_signal", "close_range", "access", "dup", "dup2", "fork", "getcwd", "chdir", "umask", "pipe", "poll", "ppoll",
+		"readv", "writev", "readlink", "statx", "getrlimit", "sysinfo", "ioctl", "mremap", "membarrier", "getpgrp", "setpgid",
+		"getpgid", "getsid", "setsid", "socket", "bind", "sendto", "recvfrom", "sendmsg", "recvmsg", "setsockopt",
+		"getsockopt", "getsockname"},
+	FeatureDB: {"flock", "fsync", "fdatasync", "ftruncate", "fallocate", "pwrite64"},
+}
+
+var architectures = map[string][]string{
+	"amd64": {"SCMP_ARCH_X86_64", "SCMP_ARCH_X86", "SCMP_ARCH_X32"},
+	"arm64": {"SCMP_ARCH_AARCH64", "SCMP_ARCH_ARM"},
+}
+
+type seccompProfile struct {
+	DefaultAction string           `json:"defaultAction"`
+	Architectures []string         `json:"architectures"`
+	Syscalls      []seccompSyscall `json:"syscalls"`
+}
+
+type seccompSyscall struct {
+	Names  []string `json:"names"`
+	Action string   `json:"action"`
+}
+
+var usedFeatures = struct {
+	sync.Mutex
+	features map[string]bool
+}{features: map[string]bool{FeatureRuntime: true}}
+
+// records usage of the feature when K8S_PACKET_SECCOMP_PROFILE_FILE is set,
+// the first use is logged with its syscalls and the profile file is rewritten to allow them,
+// syscalls already allowed in the file are kept, so features used in previous runs are not lost
+func UseFeature(feature string) {
+	file := os.Getenv("K8S_PACKET_SECCOMP_PROFILE_FILE")
+	if file == "" {
+		return
+	}
+
+	usedFeatures.Lock()
+	defer usedFeatures.Unlock()
+
+	if usedFeatures.features[feature] {
+		return
+	}
+	usedFeatures.features[feature] = true
+	slog.Info("[seccomp] Feature used", "feature", feature, "syscalls", Features[feature])
+
+	var features []string
+	for used := range usedFeatures.features {
+		features = append(features, used)
+	}
+
+	allowed, err := allowedSyscalls(file)
+	if err != nil {
+		slog.Error("[seccomp] Cannot read existing profile, leaving it unchanged", "file", file, "Error", err)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := writeSeccompProfile(&out, allowed, features...); err != nil {
+		slog.Error("[seccomp] Cannot build profile", "Error", err)
+		return
+	}
+	if err := os.WriteFile(file, out.Bytes(), 0644); err != nil {
+		slog.Error("[seccomp] Cannot write profile", "file", file, "Error", err)
+	}
+}
+
+// syscalls allowed by the existing profile file, none when the file doesn't exist or is empty
+func allowedSyscalls(file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(bytes.TrimSpace(content)) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profile seccompProfile
+	if err := json.Unmarshal(content, &profile); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, syscall := range profile.Syscalls {
+		if syscall.Action == "SCMP_ACT_ALLOW" {
+			names = append(names, syscall.Names...)
+		}
+	}
+	return names, nil
+}
+
+// seccomp profile in the format used by the container runtimes, allowing syscalls of the given features only
+func WriteSeccompProfile(w io.Writer, features ...string) error {
+	return writeSeccompProfile(w, nil, features...)
+}
+
+func writeSeccompProfile(w io.Writer, names []string, features ...string) error {
+	for _, feature := range features {
+		names = append(names, Features[feature]...)
+	}
+	slices.Sort(names)
+
+	profile := seccompProfile{
+		DefaultAction: "SCMP_ACT_ERRNO",
+		Architectures: architectures[runtime.GOARCH],
+		Syscalls:      []seccompSyscall{{Names: slices.Compact(names), Action: "SCMP_ACT_ALLOW"}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(profile)
+}
@@ -0,0 +1,94 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSeccompProfile(t *testing.T) {
+
+	var out bytes.Buffer
+
+	err := WriteSeccompProfile(&out, FeatureRuntime, FeatureTc, FeatureNetwork)
+
+	var profile seccompProfile
+	json.Unmarshal(out.Bytes(), &profile)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "SCMP_ACT_ERRNO", profile.DefaultAction)
+	assert.Len(t, profile.Syscalls, 1)
+	assert.EqualValues(t, "SCMP_ACT_ALLOW", profile.Syscalls[0].Action)
+	assert.Contains(t, profile.Syscalls[0].Names, "futex")
+	assert.Contains(t, profile.Syscalls[0].Names, "accept4")
+	assert.NotContains(t, profile.Syscalls[0].Names, "bpf")
+	assert.IsNonDecreasing(t, profile.Syscalls[0].Names)
+}
+
+func TestUseFeature(t *testing.T) {
+
+	var str bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&str, nil)))
+
+	file := filepath.Join(t.TempDir(), "seccomp.json")
+
+	UseFeature(FeatureEbpf)
+	assert.NoFileExists(t, file)
+	assert.NotContains(t, str.String(), "[seccomp] Feature used")
+
+	os.Setenv("K8S_PACKET_SECCOMP_PROFILE_FILE", file)
+	defer os.Unsetenv("K8S_PACKET_SECCOMP_PROFILE_FILE")
+
+	UseFeature(FeatureEbpf)
+	UseFeature(FeatureEbpf)
+
+	content, _ := os.ReadFile(file)
+	var profile seccompProfile
+	json.Unmarshal(content, &profile)
+
+	assert.Contains(t, profile.Syscalls[0].Names, "bpf")
+	assert.Contains(t, profile.Syscalls[0].Names, "futex")
+	assert.NotContains(t, profile.Syscalls[0].Names, "execve")
+	assert.Contains(t, str.String(), "feature=ebpf")
+	assert.Equal(t, 1, bytes.Count(str.Bytes(), []byte("[seccomp] Feature used")))
+}
+
+func TestUseFeatureMergesExistingProfile(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "seccomp.json")
+	t.Setenv("K8S_PACKET_SECCOMP_PROFILE_FILE", file)
+
+	var existing bytes.Buffer
+	writeSeccompProfile(&existing, []string{"custom_syscall"}, FeatureRuntime)
+	os.WriteFile(file, existing.Bytes(), 0644)
+
+	UseFeature(FeatureDB)
+
+	content, _ := os.ReadFile(file)
+	var profile seccompProfile
+	json.Unmarshal(content, &profile)
+
+	assert.Contains(t, profile.Syscalls[0].Names, "custom_syscall")
+	assert.Contains(t, profile.Syscalls[0].Names, "flock")
+	assert.IsNonDecreasing(t, profile.Syscalls[0].Names)
+
+	// not a profile, it is left unchanged
+	os.WriteFile(file, []byte("not a profile"), 0644)
+
+	UseFeature(FeatureExec)
+
+	content, _ = os.ReadFile(file)
+	assert.EqualValues(t, "not a profile", string(content))
+}
+
+func TestExecCoversChildProcesses(t *testing.T) {
+
+	for _, syscall := range []string{"execve", "access", "dup2", "getcwd", "fork", "wait4"} {
+		assert.Contains(t, Features[FeatureExec], syscall)
+	}
+}